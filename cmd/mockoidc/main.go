@@ -0,0 +1,34 @@
+// Command mockoidc runs a local OpenID Connect provider for trying out social
+// login without a real identity provider:
+//
+//	go run ./cmd/mockoidc -addr :9000 -client-id e-ticaret
+//
+// and configure the API with OIDC_PROVIDERS=mock, OIDC_MOCK_ISSUER=http://localhost:9000,
+// OIDC_MOCK_CLIENT_ID=e-ticaret and OIDC_MOCK_REDIRECT_URL=http://localhost:8080/auth/mock/callback.
+package main
+
+import (
+	"e-ticaret-api/oidc"
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL as seen by the API")
+	clientID := flag.String("client-id", "e-ticaret", "accepted client ID")
+	email := flag.String("email", "mock.user@example.com", "email of the signed in user")
+	unverified := flag.Bool("unverified", false, "report the email as unverified")
+	flag.Parse()
+
+	provider, err := oidc.NewMockProvider(*issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider.Email = *email
+	provider.EmailVerified = !*unverified
+
+	log.Printf("Mock OIDC provider listening on %s (issuer %s)", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
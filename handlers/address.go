@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const addressColumns = "id, user_id, title, full_name, phone, line1, line2, district, city, postal_code, country, is_default_shipping, is_default_billing, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddress(row rowScanner, address *models.Address) error {
+	return row.Scan(&address.ID, &address.UserID, &address.Title, &address.FullName, &address.Phone, &address.Line1, &address.Line2,
+		&address.District, &address.City, &address.PostalCode, &address.Country, &address.IsDefaultShipping, &address.IsDefaultBilling, &address.CreatedAt)
+}
+
+// formatAddress renders an address as the multi-line text stored on orders.
+func formatAddress(a models.Address) string {
+	lines := []string{a.FullName, a.Line1}
+	if a.Line2 != "" {
+		lines = append(lines, a.Line2)
+	}
+	lines = append(lines, strings.TrimSpace(a.PostalCode+" "+a.District+"/"+a.City), a.Country)
+	if a.Phone != "" {
+		lines = append(lines, a.Phone)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func validateAddress(a models.Address) bool {
+	return a.FullName != "" && a.Line1 != "" && a.City != "" && a.Country != ""
+}
+
+// clearDefaultAddresses unsets the default flags that address is about to take over.
+func clearDefaultAddresses(tx *sql.Tx, address models.Address) error {
+	if address.IsDefaultShipping {
+		if _, err := tx.Exec("UPDATE addresses SET is_default_shipping = ? WHERE user_id = ? AND id <> ?", false, address.UserID, address.ID); err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if _, err := tx.Exec("UPDATE addresses SET is_default_billing = ? WHERE user_id = ? AND id <> ?", false, address.UserID, address.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAddresses godoc
+// @Summary List addresses
+// @Description Get the authenticated user's address book
+// @Tags me
+// @Produce  json
+// @Success 200 {array} models.Address
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/addresses [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetAddresses() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		rows, err := db.DB.Query("SELECT "+addressColumns+" FROM addresses WHERE user_id = ? ORDER BY id", userID)
+		if err != nil {
+			http.Error(w, "Error fetching addresses", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var addresses []models.Address
+		for rows.Next() {
+			var address models.Address
+			if err := scanAddress(rows, &address); err != nil {
+				http.Error(w, "Error scanning address", http.StatusInternalServerError)
+				return
+			}
+			addresses = append(addresses, address)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(addresses)
+	})
+}
+
+// CreateAddress godoc
+// @Summary Add an address
+// @Description Add an address to the authenticated user's address book. The first address becomes the default.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param   address  body  models.Address  true  "Address"
+// @Success 201 {object} models.Address
+// @Failure 400 {string} string "Invalid request"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/addresses [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) CreateAddress() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var address models.Address
+		if err := json.NewDecoder(r.Body).Decode(&address); err != nil || !validateAddress(address) {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		address.UserID = userID
+		address.CreatedAt = time.Now()
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM addresses WHERE user_id = ?", userID).Scan(&count); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error fetching addresses", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+
+		res, err := tx.Exec("INSERT INTO addresses (user_id, title, full_name, phone, line1, line2, district, city, postal_code, country, is_default_shipping, is_default_billing, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			address.UserID, address.Title, address.FullName, address.Phone, address.Line1, address.Line2, address.District, address.City, address.PostalCode, address.Country, address.IsDefaultShipping, address.IsDefaultBilling, address.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error creating address", http.StatusInternalServerError)
+			return
+		}
+
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		address.ID = int(lastInsertID)
+
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating default address", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(address)
+	})
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Replace an address in the authenticated user's address book
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param   id       path  int             true  "Address ID"
+// @Param   address  body  models.Address  true  "Address"
+// @Success 200 {object} models.Address
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Address not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/addresses/{id} [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) UpdateAddress() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+		vars := mux.Vars(r)
+		addressID := vars["id"]
+
+		var address models.Address
+		if err := json.NewDecoder(r.Body).Decode(&address); err != nil || !validateAddress(address) {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var existing models.Address
+		if err := scanAddress(tx.QueryRow("SELECT "+addressColumns+" FROM addresses WHERE id = ? AND user_id = ?", addressID, userID), &existing); err != nil {
+			tx.Rollback()
+			http.Error(w, "Address not found", http.StatusNotFound)
+			return
+		}
+		address.ID = existing.ID
+		address.UserID = existing.UserID
+		address.CreatedAt = existing.CreatedAt
+
+		_, err = tx.Exec("UPDATE addresses SET title = ?, full_name = ?, phone = ?, line1 = ?, line2 = ?, district = ?, city = ?, postal_code = ?, country = ?, is_default_shipping = ?, is_default_billing = ? WHERE id = ?",
+			address.Title, address.FullName, address.Phone, address.Line1, address.Line2, address.District, address.City, address.PostalCode, address.Country, address.IsDefaultShipping, address.IsDefaultBilling, address.ID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating address", http.StatusInternalServerError)
+			return
+		}
+
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating default address", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(address)
+	})
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Remove an address from the authenticated user's address book. Orders keep their copy of the address.
+// @Tags me
+// @Produce  json
+// @Param   id  path  int  true  "Address ID"
+// @Success 200 {string} string "Address deleted"
+// @Failure 404 {string} string "Address not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/addresses/{id} [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) DeleteAddress() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+		vars := mux.Vars(r)
+		addressID := vars["id"]
+
+		res, err := db.DB.Exec("DELETE FROM addresses WHERE id = ? AND user_id = ?", addressID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting address", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Address not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Address deleted"})
+	})
+}
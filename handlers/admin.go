@@ -4,15 +4,26 @@ import (
 	"e-ticaret-api/models"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // GetUsers godoc
 // @Summary Get all users
-// @Description Get all users by admin
+// @Description Get a page of users by admin
 // @Tags admin
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.User
+// @Param role query string false "Role"
+// @Param sort_by query string false "Sort by: id, email or name (default id)"
+// @Param order query string false "Order (asc or desc)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.User}
+// @Failure 400 {string} string "Invalid pagination parameters"
 // @Failure 403 {string} string "Only admin can access this endpoint"
 // @Failure 500 {string} string "Error fetching users"
 // @Router /admin/users [get]
@@ -25,28 +36,65 @@ func (db *AppHandler) GetUsers() http.Handler {
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT id, email, name, role FROM users")
+		page, err := newPageRequest(r, userSorts, "id", false)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		if role := r.URL.Query().Get("role"); role != "" {
+			where += " AND role = ?"
+			args = append(args, role)
+		}
+
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+			http.Error(w, "Error fetching users", http.StatusInternalServerError)
+			return
+		}
+
+		query, queryArgs := page.keyset("SELECT id, email, name, role, verified, suspended FROM users"+where, args, "id")
+		rows, err := db.DB.Query(query, queryArgs...)
 		if err != nil {
 			http.Error(w, "Error fetching users", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var users []models.User
+		users := []models.User{}
 		for rows.Next() {
 			var user models.User
-			if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role); err != nil {
+			if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Verified, &user.Suspended); err != nil {
 				http.Error(w, "Error scanning user", http.StatusInternalServerError)
 				return
 			}
 			users = append(users, user)
 		}
 
+		next := ""
+		if len(users) > page.limit {
+			users = users[:page.limit]
+			last := users[page.limit-1]
+			var value interface{} = last.ID
+			switch page.sortBy {
+			case "email":
+				value = last.Email
+			case "name":
+				value = last.Name
+			}
+			next = page.cursor(value, last.ID)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(models.Page{Items: users, NextCursor: next, Total: total, Limit: page.limit})
 	})
 }
 
+// userSorts are the sort_by values accepted by GetUsers.
+var userSorts = map[string]string{"id": "id", "email": "email", "name": "name"}
+
 // AdminAddProduct godoc
 // @Summary Add a product by admin
 // @Description Add a new product by admin
@@ -74,12 +122,49 @@ func (db *AppHandler) AdminAddProduct() http.Handler {
 			return
 		}
 
-		_, err := db.DB.Exec("INSERT INTO products (name, description, quantity, price, seller_id, category, image_url) VALUES (?, ?, ?, ?, ?, ?, ?)",
-			product.Name, product.Description, product.Quantity, product.Price, product.SellerID, product.Category, product.ImageURL)
+		category, err := db.categoryName(product.CategoryID)
+		if err != nil {
+			http.Error(w, "Invalid category", http.StatusBadRequest)
+			return
+		}
+		product.Category = category
+
+		product.SKU = strings.TrimSpace(product.SKU)
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		res, err := tx.Exec("INSERT INTO products (name, description, quantity, price, seller_id, sku, category_id, image_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			product.Name, product.Description, product.Quantity, product.Price, product.SellerID, skuValue(product.SKU), product.CategoryID, product.ImageURL)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "SKU already in use by the seller", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error adding product", http.StatusInternalServerError)
+			return
+		}
+		lastInsertID, err := res.LastInsertId()
 		if err != nil {
+			tx.Rollback()
 			http.Error(w, "Error adding product", http.StatusInternalServerError)
 			return
 		}
+		product.ID = int(lastInsertID)
+		if err := recordProductPrice(tx, product.ID, "initial", time.Now()); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error adding product", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+		product.ArchivedAt = nil
+		db.indexProduct(product)
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(product)
@@ -88,11 +173,18 @@ func (db *AppHandler) AdminAddProduct() http.Handler {
 
 // GetAllOrders godoc
 // @Summary Get all orders by admin
-// @Description Get all orders by admin
+// @Description Get a page of all orders by admin, newest first by default
 // @Tags admin
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Order
+// @Param status query string false "Order status"
+// @Param user_id query int false "User ID"
+// @Param sort_by query string false "Sort by: id or total_price (default id)"
+// @Param order query string false "Order (asc or desc, default desc)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.Order}
+// @Failure 400 {string} string "Invalid pagination parameters"
 // @Failure 403 {string} string "Only admin can access this endpoint"
 // @Failure 500 {string} string "Error fetching orders"
 // @Router /admin/orders [get]
@@ -105,14 +197,43 @@ func (db *AppHandler) GetAllOrders() http.Handler {
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT id, user_id, total_price, created_at, status FROM orders")
+		page, err := newPageRequest(r, orderSorts, "id", true)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		if status := r.URL.Query().Get("status"); status != "" {
+			where += " AND status = ?"
+			args = append(args, status)
+		}
+		if value := r.URL.Query().Get("user_id"); value != "" {
+			userID, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+			where += " AND user_id = ?"
+			args = append(args, userID)
+		}
+
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM orders"+where, args...).Scan(&total); err != nil {
+			http.Error(w, "Error fetching orders", http.StatusInternalServerError)
+			return
+		}
+
+		query, queryArgs := page.keyset("SELECT id, user_id, total_price, created_at, status FROM orders"+where, args, "id")
+		rows, err := db.DB.Query(query, queryArgs...)
 		if err != nil {
 			http.Error(w, "Error fetching orders", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var orders []models.Order
+		orders := []models.Order{}
 		for rows.Next() {
 			var order models.Order
 			if err := rows.Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.CreatedAt, &order.Status); err != nil {
@@ -123,6 +244,189 @@ func (db *AppHandler) GetAllOrders() http.Handler {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(orders)
+		json.NewEncoder(w).Encode(orderPage(page, orders, total))
+	})
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Promote or demote a user by admin. The new role is used in tokens issued afterwards.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   id    path  int     true  "User ID"
+// @Param   role  body  string  true  "customer, seller or admin"
+// @Success 200 {string} string "User role updated"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 403 {string} string "Only admin can change roles"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Error updating user role"
+// @Router /admin/users/{id}/role [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) UpdateUserRole() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.Context().Value("userID").(int)
+		userRole := r.Context().Value("role").(string)
+		if userRole != "admin" {
+			http.Error(w, "Only admin can change roles", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		if userID == adminID {
+			http.Error(w, "Admins cannot change their own role", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Role != "customer" && req.Role != "seller" && req.Role != "admin" {
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.DB.Exec("UPDATE users SET role = ? WHERE id = ?", req.Role, userID)
+		if err != nil {
+			http.Error(w, "Error updating user role", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			var exists int
+			if err := db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", userID).Scan(&exists); err != nil || exists == 0 {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "User role updated"})
+	})
+}
+
+// SuspendUser godoc
+// @Summary Suspend or reinstate a user
+// @Description Suspend or reinstate a user by admin. Suspension revokes all of the user's refresh tokens.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   id         path  int   true  "User ID"
+// @Param   suspended  body  bool  true  "Suspended"
+// @Success 200 {string} string "User suspension updated"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 403 {string} string "Only admin can suspend users"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Error updating user"
+// @Router /admin/users/{id}/suspend [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) SuspendUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.Context().Value("userID").(int)
+		userRole := r.Context().Value("role").(string)
+		if userRole != "admin" {
+			http.Error(w, "Only admin can suspend users", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		if userID == adminID {
+			http.Error(w, "Admins cannot suspend themselves", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Suspended bool `json:"suspended"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", userID).Scan(&exists); err != nil || exists == 0 {
+			tx.Rollback()
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE users SET suspended = ? WHERE id = ?", req.Suspended, userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating user", http.StatusInternalServerError)
+			return
+		}
+
+		if req.Suspended {
+			if err := revokeSessions(tx, userID, "", time.Now()); err != nil {
+				tx.Rollback()
+				http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "User suspension updated"})
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user account
+// @Description Clear failed login attempts and lockout for a user by admin
+// @Tags admin
+// @Produce  json
+// @Param   id  path  int  true  "User ID"
+// @Success 200 {string} string "User unlocked"
+// @Failure 403 {string} string "Only admin can unlock users"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Error unlocking user"
+// @Router /admin/users/{id}/unlock [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) UnlockUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userRole := r.Context().Value("role").(string)
+		if userRole != "admin" {
+			http.Error(w, "Only admin can unlock users", http.StatusForbidden)
+			return
+		}
+
+		vars := mux.Vars(r)
+		userID := vars["id"]
+
+		var email string
+		if err := db.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if err := db.clearLoginFailures(email); err != nil {
+			http.Error(w, "Error unlocking user", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "User unlocked"})
 	})
 }
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/middleware"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiKeyScopes lists the scopes a seller can grant to an API key.
+var apiKeyScopes = map[string]bool{
+	"products:read":  true,
+	"products:write": true,
+	"orders:read":    true,
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a scoped API key for seller integrations. The key is only shown in this response.
+// @Tags seller
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"name\": \"ERP sync\", \"scopes\": [\"products:write\"], \"expires_in_days\": 90}"
+// @Success 201 {object} models.APIKey
+// @Failure 400 {string} string "Invalid request"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api-keys [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) CreateAPIKey() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || len(req.Scopes) == 0 || req.ExpiresInDays < 0 {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !apiKeyScopes[scope] {
+				http.Error(w, "Unknown scope: "+scope, http.StatusBadRequest)
+				return
+			}
+		}
+
+		prefixPart, err := randomToken(4)
+		if err != nil {
+			http.Error(w, "Error generating API key", http.StatusInternalServerError)
+			return
+		}
+		secret, err := randomToken(24)
+		if err != nil {
+			http.Error(w, "Error generating API key", http.StatusInternalServerError)
+			return
+		}
+
+		apiKey := models.APIKey{
+			Name:      req.Name,
+			Prefix:    middleware.APIKeyPrefix + prefixPart,
+			Scopes:    req.Scopes,
+			CreatedAt: time.Now(),
+		}
+		apiKey.Key = apiKey.Prefix + "_" + secret
+		if req.ExpiresInDays > 0 {
+			expiresAt := apiKey.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+			apiKey.ExpiresAt = &expiresAt
+		}
+
+		res, err := db.DB.Exec("INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			userID, apiKey.Name, apiKey.Prefix, hashToken(apiKey.Key), strings.Join(apiKey.Scopes, ","), apiKey.ExpiresAt, apiKey.CreatedAt)
+		if err != nil {
+			http.Error(w, "Error creating API key", http.StatusInternalServerError)
+			return
+		}
+
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		apiKey.ID = int(lastInsertID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(apiKey)
+	})
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description List the seller's API keys without their secrets
+// @Tags seller
+// @Produce  json
+// @Success 200 {array} models.APIKey
+// @Failure 500 {string} string "Internal server error"
+// @Router /api-keys [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetAPIKeys() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		rows, err := db.DB.Query("SELECT id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at FROM api_keys WHERE user_id = ? ORDER BY created_at DESC", userID)
+		if err != nil {
+			http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var apiKeys []models.APIKey
+		for rows.Next() {
+			var apiKey models.APIKey
+			var scopes string
+			var expiresAt, lastUsedAt, revokedAt sql.NullTime
+			if err := rows.Scan(&apiKey.ID, &apiKey.Name, &apiKey.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &apiKey.CreatedAt); err != nil {
+				http.Error(w, "Error scanning API key", http.StatusInternalServerError)
+				return
+			}
+			apiKey.Scopes = strings.Split(scopes, ",")
+			if expiresAt.Valid {
+				apiKey.ExpiresAt = &expiresAt.Time
+			}
+			if lastUsedAt.Valid {
+				apiKey.LastUsedAt = &lastUsedAt.Time
+			}
+			if revokedAt.Valid {
+				apiKey.RevokedAt = &revokedAt.Time
+			}
+			apiKeys = append(apiKeys, apiKey)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apiKeys)
+	})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke one of the seller's API keys
+// @Tags seller
+// @Produce  json
+// @Param   id  path  int  true  "API key ID"
+// @Success 200 {string} string "API key revoked"
+// @Failure 404 {string} string "API key not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api-keys/{id} [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) RevokeAPIKey() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+		vars := mux.Vars(r)
+		keyID := vars["id"]
+
+		res, err := db.DB.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL", time.Now(), keyID, userID)
+		if err != nil {
+			http.Error(w, "Error revoking API key", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked"})
+	})
+}
@@ -2,8 +2,21 @@ package handlers
 
 import (
 	"database/sql"
+	"e-ticaret-api/jwtkeys"
+	"e-ticaret-api/mailer"
+	"e-ticaret-api/oidc"
+	"e-ticaret-api/search"
+	"e-ticaret-api/storage"
 )
 
 type AppHandler struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Mailer mailer.Mailer
+	Keys   *jwtkeys.KeySet
+	// OIDCProviders maps a provider name used in /auth/{provider} routes to its configuration.
+	OIDCProviders map[string]*oidc.Provider
+	// Search is the product full-text index, kept in sync by the product handlers.
+	Search search.Index
+	// Images stores uploaded product images and their thumbnails.
+	Images storage.Store
 }
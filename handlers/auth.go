@@ -5,15 +5,10 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
-	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-
 func (db *AppHandler) Register() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("Register handler called")
@@ -23,7 +18,10 @@ func (db *AppHandler) Register() http.Handler {
 			log.Println("JSON Decode error: ", err)
 			return
 		}
-		log.Println("User data decoded: ", user)
+		log.Println("User data decoded: ", user.Email)
+
+		// Rol istemciden alınmaz; satıcılık başvuru ile, adminlik admin tarafından verilir
+		user.Role = "customer"
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -31,16 +29,26 @@ func (db *AppHandler) Register() http.Handler {
 			log.Println("Error hashing password: ", err)
 			return
 		}
-		user.Password = string(hashedPassword)
-		log.Println("Password hashed:", user.Password)
 
-		_, err = db.DB.Exec("INSERT INTO users (email, password, name, role) VALUES (?, ?, ?, ?)", user.Email, user.Password, user.Name, user.Role)
+		res, err := db.DB.Exec("INSERT INTO users (email, password, name, role, verified) VALUES (?, ?, ?, ?, ?)", user.Email, string(hashedPassword), user.Name, user.Role, false)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			log.Println("Database Insert Error: ", err)
 			return
 		}
-		log.Println("User inserted into database: ", user)
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		user.ID = int(lastInsertID)
+		user.Password = ""
+		user.Verified = false
+		log.Println("User inserted into database: ", user.Email)
+
+		if err := db.sendVerificationEmail(user.ID, user.Email); err != nil {
+			log.Println("Error sending verification email: ", err)
+		}
 
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(user); err != nil {
@@ -58,44 +66,84 @@ func (db *AppHandler) Login() http.Handler {
 			return
 		}
 
+		ip := clientIP(r)
+		wait, err := db.loginRetryAfter(creds.Email, ip)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Println("Error checking login attempts: ", err)
+			return
+		}
+		if wait > 0 {
+			tooManyAttempts(w, wait)
+			return
+		}
+
+		// E-postanın kayıtlı olup olmadığı belli olmasın diye tüm hatalarda aynı cevap dönülür
 		var storedUser models.User
-		row := db.DB.QueryRow("SELECT id, email, password, name, role FROM users WHERE email = ?", creds.Email)
-		if err := row.Scan(&storedUser.ID, &storedUser.Email, &storedUser.Password, &storedUser.Name, &storedUser.Role); err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		row := db.DB.QueryRow("SELECT id, email, password, name, role, suspended, totp_enabled FROM users WHERE email = ?", creds.Email)
+		if err := row.Scan(&storedUser.ID, &storedUser.Email, &storedUser.Password, &storedUser.Name, &storedUser.Role, &storedUser.Suspended, &storedUser.TwoFactorEnabled); err != nil {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(creds.Password))
+			if err := db.recordLoginFailure(creds.Email, ip); err != nil {
+				log.Println("Error recording login failure: ", err)
+			}
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(creds.Password)); err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-			log.Println("Password comparison error: ", err)
+			if err := db.recordLoginFailure(creds.Email, ip); err != nil {
+				log.Println("Error recording login failure: ", err)
+			}
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
 
-		expirationTime := time.Now().Add(24 * time.Hour)
-		claims := &models.Claims{
-			Username: storedUser.Email,
-			UserID:   storedUser.ID,
-			Role:     storedUser.Role,
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: expirationTime.Unix(),
-			},
+		db.completeLogin(w, r, storedUser)
+	})
+}
+
+// completeLogin finishes a login whose first factor has been checked: it refuses
+// suspended accounts, returns a two-factor challenge when one is needed, and
+// otherwise issues tokens and resets the failed login counter of the account.
+// Password and social logins both end here.
+func (db *AppHandler) completeLogin(w http.ResponseWriter, r *http.Request, user models.User) {
+	if user.Suspended {
+		http.Error(w, "Account suspended", http.StatusForbidden)
+		return
+	}
+
+	// 2FA açıksa ya da admin henüz kurmadıysa token yerine kısa ömürlü bir challenge dönülür
+	if user.TwoFactorEnabled || user.Role == "admin" {
+		purpose := purposeTwoFactor
+		if !user.TwoFactorEnabled {
+			purpose = purposeTwoFAEnroll
 		}
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		tokenString, err := token.SignedString(jwtKey)
+		challenge, err := db.issueChallenge(user, purpose)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
-			log.Println("Error signing token: ", err)
+			log.Println("Error issuing challenge: ", err)
 			return
 		}
-
-		http.SetCookie(w, &http.Cookie{
-			Name:    "token",
-			Value:   tokenString,
-			Expires: expirationTime,
-		})
-
-		if err := json.NewEncoder(w).Encode(map[string]string{"token": tokenString}); err != nil {
+		if err := json.NewEncoder(w).Encode(challenge); err != nil {
 			log.Println("Error encoding response: ", err)
 		}
-	})
+		return
+	}
+
+	tokens, err := db.issueTokens(r, user, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Println("Error issuing tokens: ", err)
+		return
+	}
+	// Hesap sayacı ancak giriş tamamlanınca sıfırlanır; 2FA'lı hesaplarda bu LoginTwoFactor'da olur
+	if err := db.clearLoginFailures(user.Email); err != nil {
+		log.Println("Error clearing login failures: ", err)
+	}
+
+	setTokenCookie(w, tokens)
+
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		log.Println("Error encoding response: ", err)
+	}
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"e-ticaret-api/models"
 	"encoding/json"
 	"log"
@@ -11,13 +12,15 @@ import (
 
 // AddToCart godoc
 // @Summary Add a product to the cart
-// @Description Add a product to the cart
+// @Description Add a product to the cart. Products sold in variants need a variant_id; the price is taken
+// @Description from the variant or the product.
 // @Tags cart
 // @Accept  json
 // @Produce  json
 // @Param cartItem body models.CartItem true "Cart Item"
 // @Success 201 {object} models.CartItem
 // @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Product not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /cart [post]
 func (db *AppHandler) AddToCart() http.Handler {
@@ -30,9 +33,25 @@ func (db *AppHandler) AddToCart() http.Handler {
 			return
 		}
 
+		// Fiyat istemciden değil, seçilen varyanttan veya üründen alınır
+		price, err := db.purchasePrice(CartItem.ProductID, CartItem.VariantID)
+		if err == errVariantRequired {
+			http.Error(w, "Bu ürün için varyant seçilmelidir.", http.StatusBadRequest)
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		CartItem.Price = price
+
 		//Kullanıcının sepeti var mı
 		var cartID int
-		err := db.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+		err = db.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
 		if err != nil {
 			//sepet yoksa sepet oluşturma
 			res, err := db.DB.Exec("INSERT INTO carts (user_id) VALUES (?)", userID)
@@ -45,8 +64,8 @@ func (db *AppHandler) AddToCart() http.Handler {
 		}
 
 		CartItem.CartID = cartID
-		_, err = db.DB.Exec("INSERT INTO cart_items (cart_id, product_id, quantity, price) VALUES (?, ?, ?, ?)",
-			CartItem.CartID, CartItem.ProductID, CartItem.Quantity, CartItem.Price)
+		_, err = db.DB.Exec("INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price) VALUES (?, ?, ?, ?, ?)",
+			CartItem.CartID, CartItem.ProductID, CartItem.VariantID, CartItem.Quantity, CartItem.Price)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -76,7 +95,7 @@ func (db *AppHandler) GetCartItems() http.Handler {
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT id, cart_id, product_id, quantity, price FROM cart_items WHERE cart_id = ?", cartID)
+		rows, err := db.DB.Query("SELECT id, cart_id, product_id, variant_id, quantity, price FROM cart_items WHERE cart_id = ?", cartID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -86,7 +105,7 @@ func (db *AppHandler) GetCartItems() http.Handler {
 		var cartItems []models.CartItem
 		for rows.Next() {
 			var cartItem models.CartItem
-			if err := rows.Scan(&cartItem.ID, &cartItem.CartID, &cartItem.ProductID, &cartItem.Quantity, &cartItem.Price); err != nil {
+			if err := rows.Scan(&cartItem.ID, &cartItem.CartID, &cartItem.ProductID, &cartItem.VariantID, &cartItem.Quantity, &cartItem.Price); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -141,6 +160,16 @@ func (db *AppHandler) RemoveFromCart() http.Handler {
 	})
 }
 
+// refreshCartItemPrice sets the price of a cart item to the current unit price of
+// its variant or product. Cart items keep the unit price; orders multiply it by
+// the quantity.
+func refreshCartItemPrice(tx *sql.Tx, itemID string, cartID int) error {
+	_, err := tx.Exec(`UPDATE cart_items ci JOIN products p ON p.id = ci.product_id
+		LEFT JOIN product_variants v ON v.id = ci.variant_id
+		SET ci.price = COALESCE(v.price, p.price) WHERE ci.id = ? AND ci.cart_id = ?`, itemID, cartID)
+	return err
+}
+
 // DecreaseItemQuantity godoc
 // @Summary Decrease the quantity of an item in the cart
 // @Description Decrease the quantity of an item in the cart
@@ -195,17 +224,7 @@ func (db *AppHandler) DecreaseItemQuantity() http.Handler {
 				return
 			}
 		} else {
-			var productPrice float64
-			err = tx.QueryRow("SELECT price FROM products WHERE id = ?", productID).Scan(&productPrice)
-			if err != nil {
-				tx.Rollback()
-				http.Error(w, "Error fetching product price", http.StatusInternalServerError)
-				return
-			}
-			log.Println("Product Price:", productPrice)
-
-			_, err = tx.Exec("UPDATE cart_items SET price = ? * quantity WHERE id = ? AND cart_id = ?", productPrice, itemID, cartID)
-			if err != nil {
+			if err := refreshCartItemPrice(tx, itemID, cartID); err != nil {
 				tx.Rollback()
 				http.Error(w, "Error updating price", http.StatusInternalServerError)
 				return
@@ -258,12 +277,13 @@ func (db *AppHandler) IncreaseItemQuantity() http.Handler {
 
 		_, err = tx.Exec("UPDATE cart_items SET quantity = quantity + 1 WHERE id = ? AND cart_id = ?", itemID, cartID)
 		if err != nil {
+			tx.Rollback()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		_, err = tx.Exec("UPDATE cart_items SET price = price * quantity WHERE id = ? AND cart_id = ?", itemID, cartID)
-		if err != nil {
+		if err := refreshCartItemPrice(tx, itemID, cartID); err != nil {
+			tx.Rollback()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"e-ticaret-api/search"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+var errUnknownCategory = errors.New("unknown category")
+
+// slugify turns a category name into a URL slug: "Ev & Yaşam" → "ev-yasam".
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range search.Normalize(name) {
+		if unicode.IsLetter(r) && r < unicode.MaxASCII || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// isDuplicateKey reports whether err is a MySQL unique key violation.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// categoryQuerier is a *sql.DB or *sql.Tx categories are read with.
+type categoryQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadCategories returns every category ordered for display.
+func (db *AppHandler) loadCategories() ([]models.Category, error) {
+	return queryCategories(db.DB, "")
+}
+
+// queryCategories reads every category ordered for display; lock is appended
+// to the query, such as FOR UPDATE inside a transaction.
+func queryCategories(q categoryQuerier, lock string) ([]models.Category, error) {
+	rows, err := q.Query("SELECT id, parent_id, name, slug, position FROM categories ORDER BY position, name " + lock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var category models.Category
+		var parentID sql.NullInt64
+		if err := rows.Scan(&category.ID, &parentID, &category.Name, &category.Slug, &category.Position); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			category.ParentID = &id
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// categoryTree nests categories under their parents, keeping their order.
+func categoryTree(categories []models.Category) []models.Category {
+	children := make(map[int][]models.Category)
+	var build func(parent int) []models.Category
+	build = func(parent int) []models.Category {
+		nodes := children[parent]
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].ID)
+		}
+		return nodes
+	}
+
+	for _, category := range categories {
+		parent := 0
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		children[parent] = append(children[parent], category)
+	}
+	tree := build(0)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+	return tree
+}
+
+// descendantIDs returns rootID and the IDs of all categories below it.
+func descendantIDs(categories []models.Category, rootID int) []int {
+	children := make(map[int][]int)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []int{rootID}
+	seen := map[int]bool{rootID: true}
+	for i := 0; i < len(ids); i++ {
+		for _, id := range children[ids[i]] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// findCategory looks a category up by numeric ID or slug.
+func findCategory(categories []models.Category, value string) (models.Category, bool) {
+	id, err := strconv.Atoi(value)
+	for _, category := range categories {
+		if (err == nil && category.ID == id) || category.Slug == value {
+			return category, true
+		}
+	}
+	return models.Category{}, false
+}
+
+// categoryFilter returns the condition that limits products to the category
+// given by ID or slug and all of its descendants.
+func (db *AppHandler) categoryFilter(value string) (string, []interface{}, error) {
+	categories, err := db.loadCategories()
+	if err != nil {
+		return "", nil, err
+	}
+	category, ok := findCategory(categories, value)
+	if !ok {
+		return "", nil, errUnknownCategory
+	}
+
+	ids := descendantIDs(categories, category.ID)
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return " AND category_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args, nil
+}
+
+// categoryName returns the name of the category with the given ID.
+func (db *AppHandler) categoryName(categoryID int) (string, error) {
+	var name string
+	err := db.DB.QueryRow("SELECT name FROM categories WHERE id = ?", categoryID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", errUnknownCategory
+	}
+	return name, err
+}
+
+// BackfillCategories moves the free-text categories of products added before
+// categories were introduced into the categories table: names with the same
+// slug become one top-level category, reusing an existing category with that
+// slug, and the products are linked to it. It is called once at startup.
+func (db *AppHandler) BackfillCategories() error {
+	var legacy bool
+	err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = 'products' AND column_name = 'category')`).Scan(&legacy)
+	if err != nil || !legacy {
+		return err
+	}
+
+	rows, err := db.DB.Query(`SELECT DISTINCT TRIM(category) FROM products
+		WHERE category_id IS NULL AND category IS NOT NULL AND TRIM(category) <> ''`)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		slug := slugify(name)
+		if slug == "" {
+			log.Printf("Category %q of existing products has no usable slug; link them to a category by hand", name)
+			continue
+		}
+
+		categoryID, err := db.categoryForSlug(name, slug)
+		if err != nil {
+			return err
+		}
+		if _, err := db.DB.Exec("UPDATE products SET category_id = ? WHERE category_id IS NULL AND TRIM(category) = ?", categoryID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryForSlug returns the category with the slug, creating a top-level
+// category with the name when there is none.
+func (db *AppHandler) categoryForSlug(name, slug string) (int, error) {
+	var categoryID int
+	err := db.DB.QueryRow("SELECT id FROM categories WHERE slug = ?", slug).Scan(&categoryID)
+	if err != sql.ErrNoRows {
+		return categoryID, err
+	}
+	res, err := db.DB.Exec("INSERT INTO categories (parent_id, name, slug, position) VALUES (?, ?, ?, ?)", nil, name, slug, 0)
+	// Başka bir sunucu aynı kategoriyi az önce eklemiş olabilir
+	if isDuplicateKey(err) {
+		err = db.DB.QueryRow("SELECT id FROM categories WHERE slug = ?", slug).Scan(&categoryID)
+		return categoryID, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// reindexCategoryProducts refreshes the search documents of a category's products after a rename.
+func (db *AppHandler) reindexCategoryProducts(categoryID int) error {
+	rows, err := db.DB.Query("SELECT "+productColumns+" FROM products WHERE category_id = ? AND archived_at IS NULL", categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return err
+		}
+		db.indexProduct(product)
+	}
+	return rows.Err()
+}
+
+// GetCategories godoc
+// @Summary Get the category tree
+// @Description Get all categories nested under their parents, siblings ordered by position and name
+// @Tags categories
+// @Produce  json
+// @Success 200 {array} models.Category
+// @Failure 500 {string} string "Internal server error"
+// @Router /categories [get]
+func (db *AppHandler) GetCategories() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		categories, err := db.loadCategories()
+		if err != nil {
+			http.Error(w, "Error fetching categories", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(categoryTree(categories))
+	})
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Create a category by admin. The slug is derived from the name when omitted.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   category  body  models.Category  true  "Category"
+// @Success 201 {object} models.Category
+// @Failure 400 {string} string "Invalid request"
+// @Failure 409 {string} string "Slug already in use"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/categories [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) CreateCategory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var category models.Category
+		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		category.Name = strings.TrimSpace(category.Name)
+		if category.Slug == "" {
+			category.Slug = category.Name
+		}
+		category.Slug = slugify(category.Slug)
+		if category.Name == "" || category.Slug == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+		if category.ParentID != nil {
+			if _, err := db.categoryName(*category.ParentID); err != nil {
+				http.Error(w, "Parent category not found", http.StatusBadRequest)
+				return
+			}
+		}
+
+		res, err := db.DB.Exec("INSERT INTO categories (parent_id, name, slug, position) VALUES (?, ?, ?, ?)", category.ParentID, category.Name, category.Slug, category.Position)
+		if isDuplicateKey(err) {
+			http.Error(w, "Slug already in use", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error creating category", http.StatusInternalServerError)
+			return
+		}
+
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		category.ID = int(lastInsertID)
+		category.Children = nil
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(category)
+	})
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Rename, re-slug, move or reorder a category by admin. Only the fields sent are changed;
+// @Description "parent_id": null moves the category to the top level.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   id    path  int     true  "Category ID"
+// @Param   body  body  object  true  "{\"name\": \"...\", \"slug\": \"...\", \"parent_id\": 1, \"position\": 2}"
+// @Success 200 {object} models.Category
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Category not found"
+// @Failure 409 {string} string "Slug already in use"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/categories/{id} [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) UpdateCategory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			return
+		}
+
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		// Tüm kategoriler kilitlenir; aynı anda yapılan iki taşıma döngü kontrolünü birlikte geçemez
+		categories, err := queryCategories(tx, "FOR UPDATE")
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error fetching categories", http.StatusInternalServerError)
+			return
+		}
+		category, ok := findCategory(categories, strconv.Itoa(categoryID))
+		if !ok {
+			tx.Rollback()
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		oldName := category.Name
+
+		// Gönderilmeyen alanlar değişmez; parent_id için null kök seviyesine taşır
+		if raw, ok := req["name"]; ok {
+			if err := json.Unmarshal(raw, &category.Name); err != nil || strings.TrimSpace(category.Name) == "" {
+				tx.Rollback()
+				http.Error(w, "Invalid name", http.StatusBadRequest)
+				return
+			}
+			category.Name = strings.TrimSpace(category.Name)
+		}
+		if raw, ok := req["slug"]; ok {
+			if err := json.Unmarshal(raw, &category.Slug); err != nil || slugify(category.Slug) == "" {
+				tx.Rollback()
+				http.Error(w, "Invalid slug", http.StatusBadRequest)
+				return
+			}
+			category.Slug = slugify(category.Slug)
+		}
+		if raw, ok := req["position"]; ok {
+			if err := json.Unmarshal(raw, &category.Position); err != nil {
+				tx.Rollback()
+				http.Error(w, "Invalid position", http.StatusBadRequest)
+				return
+			}
+		}
+		if raw, ok := req["parent_id"]; ok {
+			var parentID *int
+			if err := json.Unmarshal(raw, &parentID); err != nil {
+				tx.Rollback()
+				http.Error(w, "Invalid parent", http.StatusBadRequest)
+				return
+			}
+			if parentID != nil {
+				if _, ok := findCategory(categories, strconv.Itoa(*parentID)); !ok {
+					tx.Rollback()
+					http.Error(w, "Parent category not found", http.StatusBadRequest)
+					return
+				}
+				// Kategori kendi altına taşınırsa ağaçta döngü oluşur
+				for _, id := range descendantIDs(categories, category.ID) {
+					if id == *parentID {
+						tx.Rollback()
+						http.Error(w, "A category cannot be moved under itself", http.StatusBadRequest)
+						return
+					}
+				}
+			}
+			category.ParentID = parentID
+		}
+
+		_, err = tx.Exec("UPDATE categories SET parent_id = ?, name = ?, slug = ?, position = ? WHERE id = ?", category.ParentID, category.Name, category.Slug, category.Position, category.ID)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "Slug already in use", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating category", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		if category.Name != oldName {
+			if err := db.reindexCategoryProducts(category.ID); err != nil {
+				http.Error(w, "Error updating search index", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(category)
+	})
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category by admin. Categories with subcategories or products cannot be deleted.
+// @Tags admin
+// @Produce  json
+// @Param   id  path  int  true  "Category ID"
+// @Success 200 {string} string "Category deleted"
+// @Failure 404 {string} string "Category not found"
+// @Failure 409 {string} string "Category is not empty"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/categories/{id} [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) DeleteCategory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		categoryID := vars["id"]
+
+		var children, products int
+		err := db.DB.QueryRow("SELECT (SELECT COUNT(*) FROM categories WHERE parent_id = ?), (SELECT COUNT(*) FROM products WHERE category_id = ?)", categoryID, categoryID).Scan(&children, &products)
+		if err != nil {
+			http.Error(w, "Error fetching category", http.StatusInternalServerError)
+			return
+		}
+		if children > 0 || products > 0 {
+			http.Error(w, "Category is not empty", http.StatusConflict)
+			return
+		}
+
+		res, err := db.DB.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+		if err != nil {
+			http.Error(w, "Error deleting category", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted"})
+	})
+}
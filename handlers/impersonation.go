@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"e-ticaret-api/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+const impersonationTTL = 30 * time.Minute
+
+// ImpersonateUser godoc
+// @Summary Impersonate a user
+// @Description Issue a short-lived token that acts as the user for support. The token has no refresh token,
+// @Description cannot be used for account or security changes, and every request made with it is audited.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   id    path  int     true  "User ID"
+// @Param   body  body  object  true  "{\"reason\": \"Ticket #1234: cart total is wrong\"}"
+// @Success 200 {object} models.ImpersonationToken
+// @Failure 400 {string} string "A reason is required"
+// @Failure 403 {string} string "Admins cannot be impersonated"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/users/{id}/impersonate [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) ImpersonateUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin := r.Context().Value("claims").(*models.Claims)
+		// Taklit tokenıyla yeni bir taklit başlatılamaz
+		if admin.Role != "admin" || admin.ImpersonatorID != 0 {
+			http.Error(w, "Only admin can impersonate users", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, role FROM users WHERE id = ? AND deleted_at IS NULL", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Role); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if user.Role == "admin" {
+			http.Error(w, "Admins cannot be impersonated", http.StatusForbidden)
+			return
+		}
+
+		jti, err := randomToken(16)
+		if err != nil {
+			http.Error(w, "Error issuing token", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		expirationTime := now.Add(impersonationTTL)
+		claims := &models.Claims{
+			Username:       user.Email,
+			UserID:         user.ID,
+			Role:           user.Role,
+			ImpersonatorID: admin.UserID,
+			StandardClaims: jwt.StandardClaims{
+				Id:        jti,
+				IssuedAt:  now.Unix(),
+				ExpiresAt: expirationTime.Unix(),
+			},
+		}
+		tokenString, err := db.Keys.Sign(claims)
+		if err != nil {
+			http.Error(w, "Error issuing token", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = db.DB.Exec("INSERT INTO impersonations (id, admin_id, user_id, reason, ip, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			jti, admin.UserID, user.ID, req.Reason, clientIP(r), now, expirationTime)
+		if err != nil {
+			http.Error(w, "Error recording impersonation", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ImpersonationToken{Token: tokenString, UserID: user.ID, ExpiresAt: expirationTime.Unix()})
+	})
+}
+
+// GetImpersonationAudit godoc
+// @Summary Impersonation audit log
+// @Description List requests made with impersonation tokens, newest first. Filter by admin_id or user_id.
+// @Tags admin
+// @Produce  json
+// @Param   admin_id  query  int  false  "Acting admin ID"
+// @Param   user_id   query  int  false  "Impersonated user ID"
+// @Param   limit     query  int  false  "Maximum number of records (default 100, max 1000)"
+// @Success 200 {array} models.ImpersonationRequest
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/impersonation-audit [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetImpersonationAudit() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := "SELECT id, impersonation_id, admin_id, user_id, method, path, status, ip, created_at FROM impersonation_requests WHERE 1 = 1"
+		var args []interface{}
+		for _, filter := range []string{"admin_id", "user_id"} {
+			value := r.URL.Query().Get(filter)
+			if value == "" {
+				continue
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid filter", http.StatusBadRequest)
+				return
+			}
+			query += " AND " + filter + " = ?"
+			args = append(args, id)
+		}
+
+		limit := 100
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				http.Error(w, "Invalid filter", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		query += " ORDER BY id DESC LIMIT ?"
+		args = append(args, limit)
+
+		rows, err := db.DB.Query(query, args...)
+		if err != nil {
+			http.Error(w, "Error fetching audit log", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		records := []models.ImpersonationRequest{}
+		for rows.Next() {
+			var record models.ImpersonationRequest
+			if err := rows.Scan(&record.ID, &record.ImpersonationID, &record.AdminID, &record.UserID, &record.Method, &record.Path, &record.Status, &record.IP, &record.CreatedAt); err != nil {
+				http.Error(w, "Error scanning audit log", http.StatusInternalServerError)
+				return
+			}
+			records = append(records, record)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	})
+}
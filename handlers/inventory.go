@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// reservationTTL is how long checkout holds the stock of a cart.
+	reservationTTL = 15 * time.Minute
+	// reservationExpiryInterval is how often expired reservations are released.
+	reservationExpiryInterval = 30 * time.Second
+)
+
+var (
+	errInsufficientStock  = errors.New("insufficient stock")
+	errInvalidQuantity    = errors.New("invalid quantity")
+	errProductUnavailable = errors.New("product is no longer available")
+	errStockBelowReserved = errors.New("stock is below the quantity reserved at checkout")
+)
+
+// sortStockItems orders items by product and variant, the order their rows are updated in.
+func sortStockItems(items []models.StockReservationItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ProductID != items[j].ProductID {
+			return items[i].ProductID < items[j].ProductID
+		}
+		if items[i].VariantID == nil || items[j].VariantID == nil {
+			return items[i].VariantID == nil && items[j].VariantID != nil
+		}
+		return *items[i].VariantID < *items[j].VariantID
+	})
+}
+
+// lockStock locks the rows of the given products and of their variants in id
+// order, so transactions changing the same stock wait for each other instead
+// of deadlocking. The user's active reservations are included because they are
+// released in the same transaction.
+func lockStock(tx *sql.Tx, userID int, items []models.StockReservationItem) error {
+	productIDs := make(map[int]bool)
+	for _, item := range items {
+		productIDs[item.ProductID] = true
+	}
+	rows, err := tx.Query(`SELECT ri.product_id FROM stock_reservation_items ri
+		JOIN stock_reservations r ON r.id = ri.reservation_id WHERE r.user_id = ? AND r.status = 'active'`, userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return err
+		}
+		productIDs[productID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(productIDs))
+	for id := range productIDs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	for _, query := range []string{
+		"SELECT id FROM products WHERE id IN (" + placeholders + ") ORDER BY id FOR UPDATE",
+		"SELECT id FROM product_variants WHERE product_id IN (" + placeholders + ") ORDER BY id FOR UPDATE",
+	} {
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// takeStock decreases the stock of a product, and of its variant, by the item's
+// quantity. The decrement only happens while enough is left, so the same stock
+// cannot be sold twice; otherwise errInsufficientStock is returned.
+func takeStock(tx *sql.Tx, item models.StockReservationItem) error {
+	if item.Quantity <= 0 {
+		return errInvalidQuantity
+	}
+	if item.VariantID != nil {
+		res, err := tx.Exec("UPDATE product_variants SET quantity = quantity - ? WHERE id = ? AND product_id = ? AND quantity >= ?",
+			item.Quantity, *item.VariantID, item.ProductID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errInsufficientStock
+		}
+	}
+	// Ürün stoğu varyantlı ürünlerde varyant stoklarının toplamıdır, birlikte azaltılır
+	res, err := tx.Exec("UPDATE products SET quantity = quantity - ? WHERE id = ? AND quantity >= ? AND archived_at IS NULL",
+		item.Quantity, item.ProductID, item.Quantity)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
+
+// availableStock converts the stock a seller sets for a product, or for one of
+// its variants, into the quantity to store. Stored quantities exclude the stock
+// held by active reservations, which is added back when they end, so the held
+// quantity is subtracted; errStockBelowReserved is returned when it is larger.
+// The caller must hold the lock of the product row.
+func availableStock(tx *sql.Tx, productID int, variantID *int, stock int) (int, error) {
+	query := `SELECT COALESCE(SUM(ri.quantity), 0) FROM stock_reservation_items ri
+		JOIN stock_reservations r ON r.id = ri.reservation_id WHERE r.status = 'active' AND ri.product_id = ?`
+	args := []interface{}{productID}
+	if variantID != nil {
+		query += " AND ri.variant_id = ?"
+		args = append(args, *variantID)
+	}
+	var reserved int
+	if err := tx.QueryRow(query+" LOCK IN SHARE MODE", args...).Scan(&reserved); err != nil {
+		return 0, err
+	}
+	if stock < reserved {
+		return 0, errStockBelowReserved
+	}
+	return stock - reserved, nil
+}
+
+// returnStock gives the item's quantity back to its product and variant.
+func returnStock(tx *sql.Tx, item models.StockReservationItem) error {
+	if item.VariantID != nil {
+		res, err := tx.Exec("UPDATE product_variants SET quantity = quantity + ? WHERE id = ? AND product_id = ?",
+			item.Quantity, *item.VariantID, item.ProductID)
+		if err != nil {
+			return err
+		}
+		// Silinen varyantın stoğu ürün toplamından zaten çıkarılmıştır
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+	}
+	_, err := tx.Exec("UPDATE products SET quantity = quantity + ? WHERE id = ?", item.Quantity, item.ProductID)
+	return err
+}
+
+// cartStockItems returns the items of a cart in the order their stock is taken.
+// errProductUnavailable is returned when the cart holds an archived product.
+func cartStockItems(tx *sql.Tx, cartID int) ([]models.StockReservationItem, error) {
+	rows, err := tx.Query(`SELECT ci.product_id, ci.variant_id, ci.quantity, p.archived_at IS NOT NULL FROM cart_items ci
+		JOIN products p ON p.id = ci.product_id WHERE ci.cart_id = ?`, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.StockReservationItem
+	for rows.Next() {
+		var item models.StockReservationItem
+		var archived bool
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity, &archived); err != nil {
+			return nil, err
+		}
+		if archived {
+			return nil, errProductUnavailable
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortStockItems(items)
+	return items, nil
+}
+
+// releaseReservation returns the stock held by a locked, active reservation and
+// closes it with the given status.
+func releaseReservation(tx *sql.Tx, reservationID int, status string) error {
+	rows, err := tx.Query("SELECT product_id, variant_id, quantity FROM stock_reservation_items WHERE reservation_id = ?", reservationID)
+	if err != nil {
+		return err
+	}
+	var items []models.StockReservationItem
+	for rows.Next() {
+		var item models.StockReservationItem
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sortStockItems(items)
+	for _, item := range items {
+		if err := returnStock(tx, item); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE stock_reservations SET status = ? WHERE id = ?", status, reservationID)
+	return err
+}
+
+// releaseUserReservations releases every active reservation of the user and
+// returns how many there were.
+func releaseUserReservations(tx *sql.Tx, userID int, status string) (int, error) {
+	rows, err := tx.Query("SELECT id FROM stock_reservations WHERE user_id = ? AND status = 'active' ORDER BY id FOR UPDATE", userID)
+	if err != nil {
+		return 0, err
+	}
+	var reservationIDs []int
+	for rows.Next() {
+		var reservationID int
+		if err := rows.Scan(&reservationID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reservationIDs = append(reservationIDs, reservationID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, reservationID := range reservationIDs {
+		if err := releaseReservation(tx, reservationID, status); err != nil {
+			return 0, err
+		}
+	}
+	return len(reservationIDs), nil
+}
+
+// BeginCheckout godoc
+// @Summary Begin checkout
+// @Description Reserve the stock of the items in the cart for 15 minutes while the user completes checkout.
+// @Description Reserved stock is not available to others; it is used by POST /order and given back when checkout
+// @Description is cancelled or the reservation expires. Beginning checkout again replaces the previous reservation.
+// @Tags orders
+// @Produce  json
+// @Success 201 {object} models.StockReservation
+// @Failure 400 {string} string "Not enough product quantity"
+// @Failure 404 {string} string "Cart not found"
+// @Failure 409 {string} string "Product is no longer available"
+// @Failure 500 {string} string "Internal server error"
+// @Router /checkout [post]
+func (db *AppHandler) BeginCheckout() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		// Sepet satırı kilitlenir; aynı kullanıcının eşzamanlı ödeme adımları sırayla işlenir
+		var cartID int
+		err = tx.QueryRow("SELECT id FROM carts WHERE user_id = ? FOR UPDATE", userID).Scan(&cartID)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			http.Error(w, "Cart not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		items, err := cartStockItems(tx, cartID)
+		if err == errProductUnavailable {
+			tx.Rollback()
+			http.Error(w, "Product is no longer available", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(items) == 0 {
+			tx.Rollback()
+			http.Error(w, "Cart is empty", http.StatusBadRequest)
+			return
+		}
+
+		if err := lockStock(tx, userID, items); err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := releaseUserReservations(tx, userID, "released"); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error releasing stock reservation", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range items {
+			err := takeStock(tx, item)
+			if err == errInsufficientStock || err == errInvalidQuantity {
+				tx.Rollback()
+				http.Error(w, "Not enough product quantity", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error reserving stock", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		now := time.Now()
+		reservation := models.StockReservation{UserID: userID, Status: "active", ExpiresAt: now.Add(reservationTTL), CreatedAt: now, Items: items}
+		res, err := tx.Exec("INSERT INTO stock_reservations (user_id, status, expires_at, created_at) VALUES (?, ?, ?, ?)",
+			reservation.UserID, reservation.Status, reservation.ExpiresAt, reservation.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error reserving stock", http.StatusInternalServerError)
+			return
+		}
+		reservationID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		reservation.ID = int(reservationID)
+
+		for _, item := range items {
+			_, err := tx.Exec("INSERT INTO stock_reservation_items (reservation_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)",
+				reservation.ID, item.ProductID, item.VariantID, item.Quantity)
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error reserving stock", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(reservation)
+	})
+}
+
+// CancelCheckout godoc
+// @Summary Cancel checkout
+// @Description Release the stock reserved by the user's checkout
+// @Tags orders
+// @Produce  json
+// @Success 200 {string} string "Reservation released"
+// @Failure 404 {string} string "No active checkout"
+// @Failure 500 {string} string "Internal server error"
+// @Router /checkout [delete]
+func (db *AppHandler) CancelCheckout() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		var cartID int
+		err = tx.QueryRow("SELECT id FROM carts WHERE user_id = ? FOR UPDATE", userID).Scan(&cartID)
+		if err != nil && err != sql.ErrNoRows {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := lockStock(tx, userID, nil); err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		released, err := releaseUserReservations(tx, userID, "released")
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error releasing stock reservation", http.StatusInternalServerError)
+			return
+		}
+		if released == 0 {
+			tx.Rollback()
+			http.Error(w, "No active checkout", http.StatusNotFound)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Stok rezervasyonu kaldırıldı."})
+	})
+}
+
+// StartReservationExpiry releases expired stock reservations now and then every 30 seconds.
+func (db *AppHandler) StartReservationExpiry() {
+	go func() {
+		ticker := time.NewTicker(reservationExpiryInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := db.ExpireReservations(time.Now()); err != nil {
+				log.Println("Error releasing expired stock reservations: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ExpireReservations gives back the stock of the active reservations that
+// expired before now and returns how many were released.
+func (db *AppHandler) ExpireReservations(now time.Time) (int, error) {
+	rows, err := db.DB.Query("SELECT id, user_id FROM stock_reservations WHERE status = 'active' AND expires_at <= ? ORDER BY id", now)
+	if err != nil {
+		return 0, err
+	}
+	type expired struct{ reservationID, userID int }
+	var reservations []expired
+	for rows.Next() {
+		var reservation expired
+		if err := rows.Scan(&reservation.reservationID, &reservation.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, reservation := range reservations {
+		ok, err := db.expireReservation(reservation.reservationID, reservation.userID, now)
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// expireReservation releases one expired reservation. It reports false when the
+// reservation was used or released in the meantime.
+func (db *AppHandler) expireReservation(reservationID, userID int, now time.Time) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	if err := lockStock(tx, userID, nil); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM stock_reservations WHERE id = ? AND status = 'active' AND expires_at <= ? FOR UPDATE", reservationID, now).Scan(&id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := releaseReservation(tx, reservationID, "expired"); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
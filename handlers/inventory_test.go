@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// The tests in this file need a MySQL database. They are skipped unless
+// TEST_DATABASE_URL holds its DSN, e.g.
+// root:secret@tcp(localhost:3306)/eticaret_test?parseTime=true
+// The tables they use are created from testdata/inventory_schema.sql.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Skipf("MySQL is not available: %v", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		t.Skipf("MySQL is not available: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	schema, err := os.ReadFile("testdata/inventory_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range strings.Split(string(schema), ";") {
+		if strings.Contains(statement, "CREATE") {
+			if _, err := conn.Exec(statement); err != nil {
+				t.Fatalf("schema: %v", err)
+			}
+		}
+	}
+	return conn
+}
+
+// stockFixture is a product, optionally sold in one variant, and buyers who
+// each have one piece of it in their cart and a default address.
+type stockFixture struct {
+	sellerID   int
+	categoryID int
+	productID  int
+	variantID  *int
+	buyerIDs   []int
+}
+
+func newStockFixture(t *testing.T, conn *sql.DB, stock, buyers int, variant bool) *stockFixture {
+	t.Helper()
+	f := &stockFixture{}
+	t.Cleanup(func() { f.cleanup(t, conn) })
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	mustExec := func(query string, args ...interface{}) int {
+		t.Helper()
+		res, err := conn.Exec(query, args...)
+		if err != nil {
+			t.Fatalf("fixture: %v", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			t.Fatalf("fixture: %v", err)
+		}
+		return int(id)
+	}
+
+	f.sellerID = mustExec("INSERT INTO users (email, password, name, role, verified) VALUES (?, ?, ?, ?, ?)",
+		"seller-"+suffix+"@example.com", "", "Stock Test Seller", "seller", true)
+	f.categoryID = mustExec("INSERT INTO categories (parent_id, name, slug, position) VALUES (?, ?, ?, ?)",
+		nil, "Stock Test "+suffix, "stock-test-"+suffix, 0)
+	f.productID = mustExec("INSERT INTO products (name, description, quantity, price, seller_id, sku, category_id, image_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		"Stock Test Product", "", stock, 10.0, f.sellerID, nil, f.categoryID, "")
+	price := 10.0
+	if variant {
+		variantID := mustExec("INSERT INTO product_variants (product_id, sku, price, quantity, image_url) VALUES (?, ?, ?, ?, ?)",
+			f.productID, "STOCK-TEST-"+suffix, 12.0, stock, "")
+		f.variantID = &variantID
+		price = 12.0
+	}
+
+	for i := 0; i < buyers; i++ {
+		buyerID := mustExec("INSERT INTO users (email, password, name, role, verified) VALUES (?, ?, ?, ?, ?)",
+			fmt.Sprintf("buyer-%d-%s@example.com", i, suffix), "", "Stock Test Buyer", "customer", true)
+		f.buyerIDs = append(f.buyerIDs, buyerID)
+		mustExec("INSERT INTO addresses (user_id, title, full_name, phone, line1, line2, district, city, postal_code, country, is_default_shipping, is_default_billing, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			buyerID, "Ev", "Stock Test Buyer", "5550000000", "Test Sok. 1", "", "Kadıköy", "İstanbul", "34710", "TR", true, true, time.Now())
+		cartID := mustExec("INSERT INTO carts (user_id) VALUES (?)", buyerID)
+		mustExec("INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price) VALUES (?, ?, ?, ?, ?)",
+			cartID, f.productID, f.variantID, 1, price)
+	}
+	return f
+}
+
+// quantities returns the stock of the product and, when it has one, of its variant.
+func (f *stockFixture) quantities(conn *sql.DB) (product, variant int, err error) {
+	if err := conn.QueryRow("SELECT quantity FROM products WHERE id = ?", f.productID).Scan(&product); err != nil {
+		return 0, 0, err
+	}
+	if f.variantID != nil {
+		if err := conn.QueryRow("SELECT quantity FROM product_variants WHERE id = ?", *f.variantID).Scan(&variant); err != nil {
+			return 0, 0, err
+		}
+	}
+	return product, variant, nil
+}
+
+// checkQuantities fails the test unless the product, and its variant, have the given stock.
+func (f *stockFixture) checkQuantities(t *testing.T, conn *sql.DB, want int) {
+	t.Helper()
+	product, variant, err := f.quantities(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product != want {
+		t.Errorf("products.quantity = %d, want %d", product, want)
+	}
+	if f.variantID != nil && variant != want {
+		t.Errorf("product_variants.quantity = %d, want %d", variant, want)
+	}
+}
+
+// reservations counts the buyers' reservations by status.
+func (f *stockFixture) reservations(t *testing.T, conn *sql.DB) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for _, buyerID := range f.buyerIDs {
+		rows, err := conn.Query("SELECT status FROM stock_reservations WHERE user_id = ?", buyerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var status string
+			if err := rows.Scan(&status); err != nil {
+				rows.Close()
+				t.Fatal(err)
+			}
+			counts[status]++
+		}
+		rows.Close()
+	}
+	return counts
+}
+
+// watch checks the stock every millisecond until the returned function is
+// called, which reports whether it ever dropped below zero.
+func (f *stockFixture) watch(conn *sql.DB) func() error {
+	done := make(chan struct{})
+	watched := make(chan error, 1)
+	go func() {
+		for {
+			product, variant, err := f.quantities(conn)
+			if err == nil && (product < 0 || variant < 0) {
+				err = fmt.Errorf("stock went negative: product %d, variant %d", product, variant)
+			}
+			if err != nil {
+				watched <- err
+				return
+			}
+			select {
+			case <-done:
+				watched <- nil
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	return func() error {
+		close(done)
+		return <-watched
+	}
+}
+
+func (f *stockFixture) cleanup(t *testing.T, conn *sql.DB) {
+	userIDs := append([]int{f.sellerID}, f.buyerIDs...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+	args := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		args[i] = id
+	}
+	for _, query := range []string{
+		"DELETE FROM order_items WHERE order_id IN (SELECT id FROM orders WHERE user_id IN (" + placeholders + "))",
+		"DELETE FROM orders WHERE user_id IN (" + placeholders + ")",
+		"DELETE FROM stock_reservation_items WHERE reservation_id IN (SELECT id FROM stock_reservations WHERE user_id IN (" + placeholders + "))",
+		"DELETE FROM stock_reservations WHERE user_id IN (" + placeholders + ")",
+		"DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id IN (" + placeholders + "))",
+		"DELETE FROM carts WHERE user_id IN (" + placeholders + ")",
+		"DELETE FROM addresses WHERE user_id IN (" + placeholders + ")",
+		"DELETE FROM product_variants WHERE product_id IN (SELECT id FROM products WHERE seller_id IN (" + placeholders + "))",
+		"DELETE FROM product_price_history WHERE product_id IN (SELECT id FROM products WHERE seller_id IN (" + placeholders + "))",
+		"DELETE FROM products WHERE seller_id IN (" + placeholders + ")",
+		"DELETE FROM users WHERE id IN (" + placeholders + ")",
+	} {
+		if _, err := conn.Exec(query, args...); err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	}
+	if f.categoryID != 0 {
+		if _, err := conn.Exec("DELETE FROM categories WHERE id = ?", f.categoryID); err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	}
+}
+
+// serve calls handler as the given customer and returns the response status.
+func serve(handler http.Handler, userID int, method, path, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "userID", userID)
+	ctx = context.WithValue(ctx, "role", "customer")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(ctx))
+	return rec.Code, rec.Body.String()
+}
+
+// concurrently calls fn for every user at the same time and returns the users
+// whose call returned true.
+func concurrently(userIDs []int, fn func(userID int) bool) []int {
+	var mu sync.Mutex
+	var succeeded []int
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for _, userID := range userIDs {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			<-start
+			if fn(userID) {
+				mu.Lock()
+				succeeded = append(succeeded, userID)
+				mu.Unlock()
+			}
+		}(userID)
+	}
+	close(start)
+	wg.Wait()
+	return succeeded
+}
+
+// expect returns a call of handler for concurrently that succeeds with the
+// status ok, fails with the status failed and reports any other status.
+func expect(t *testing.T, handler http.Handler, method, path, body string, ok, failed int) func(int) bool {
+	return func(userID int) bool {
+		code, response := serve(handler, userID, method, path, body)
+		if code != ok && code != failed {
+			t.Errorf("user %d: %s %s returned %d: %s", userID, method, path, code, response)
+		}
+		return code == ok
+	}
+}
+
+const testStock, testBuyers = 3, 12
+
+// forEachProduct runs fn for a product without variants and for one sold in a variant.
+func forEachProduct(t *testing.T, fn func(t *testing.T, variant bool)) {
+	t.Run("product", func(t *testing.T) { fn(t, false) })
+	t.Run("variant", func(t *testing.T) { fn(t, true) })
+}
+
+// TestConcurrentCheckoutDoesNotOversell lets more buyers than there is stock
+// check out or order at the same time and checks that exactly the stock is
+// sold and the stock of the product and its variant never drops below zero.
+func TestConcurrentCheckoutDoesNotOversell(t *testing.T) {
+	conn := testDB(t)
+	db := &AppHandler{DB: conn}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+		body    string
+	}{
+		{"order", db.CreateOrder(), "/order", "{}"},
+		{"checkout", db.BeginCheckout(), "/checkout", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachProduct(t, func(t *testing.T, variant bool) {
+				f := newStockFixture(t, conn, testStock, testBuyers, variant)
+
+				stop := f.watch(conn)
+				succeeded := concurrently(f.buyerIDs, expect(t, tt.handler, http.MethodPost, tt.path, tt.body, http.StatusCreated, http.StatusBadRequest))
+				if err := stop(); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(succeeded) != testStock {
+					t.Errorf("%d calls succeeded, want %d", len(succeeded), testStock)
+				}
+				f.checkQuantities(t, conn, 0)
+			})
+		})
+	}
+}
+
+// TestCheckoutThenOrder checks that the buyers who reserved the stock can order
+// it and that the others cannot.
+func TestCheckoutThenOrder(t *testing.T) {
+	conn := testDB(t)
+	db := &AppHandler{DB: conn}
+
+	forEachProduct(t, func(t *testing.T, variant bool) {
+		f := newStockFixture(t, conn, testStock, testBuyers, variant)
+
+		stop := f.watch(conn)
+		reserved := concurrently(f.buyerIDs, expect(t, db.BeginCheckout(), http.MethodPost, "/checkout", "", http.StatusCreated, http.StatusBadRequest))
+		ordered := concurrently(f.buyerIDs, expect(t, db.CreateOrder(), http.MethodPost, "/order", "{}", http.StatusCreated, http.StatusBadRequest))
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(reserved) != testStock {
+			t.Fatalf("%d checkouts succeeded, want %d", len(reserved), testStock)
+		}
+		if len(ordered) != testStock {
+			t.Errorf("%d orders succeeded, want %d", len(ordered), testStock)
+		}
+		winners := make(map[int]bool)
+		for _, userID := range reserved {
+			winners[userID] = true
+		}
+		for _, userID := range ordered {
+			if !winners[userID] {
+				t.Errorf("user %d ordered without a reservation while the stock was reserved", userID)
+			}
+		}
+		f.checkQuantities(t, conn, 0)
+		if counts := f.reservations(t, conn); counts["completed"] != testStock || counts["active"] != 0 {
+			t.Errorf("reservations = %v, want %d completed", counts, testStock)
+		}
+	})
+}
+
+// TestCancelCheckoutReturnsStock checks that cancelling checkout gives the
+// reserved stock back once.
+func TestCancelCheckoutReturnsStock(t *testing.T) {
+	conn := testDB(t)
+	db := &AppHandler{DB: conn}
+
+	forEachProduct(t, func(t *testing.T, variant bool) {
+		f := newStockFixture(t, conn, testStock, testBuyers, variant)
+
+		reserved := concurrently(f.buyerIDs, expect(t, db.BeginCheckout(), http.MethodPost, "/checkout", "", http.StatusCreated, http.StatusBadRequest))
+		if len(reserved) != testStock {
+			t.Fatalf("%d checkouts succeeded, want %d", len(reserved), testStock)
+		}
+		f.checkQuantities(t, conn, 0)
+
+		// Her kullanıcı iki kez iptal eder; stok yalnızca bir kez iade edilmelidir
+		twice := append(append([]int{}, f.buyerIDs...), f.buyerIDs...)
+		cancelled := concurrently(twice, expect(t, db.CancelCheckout(), http.MethodDelete, "/checkout", "", http.StatusOK, http.StatusNotFound))
+		if len(cancelled) != testStock {
+			t.Errorf("%d cancellations succeeded, want %d", len(cancelled), testStock)
+		}
+		f.checkQuantities(t, conn, testStock)
+		if counts := f.reservations(t, conn); counts["released"] != testStock || counts["active"] != 0 {
+			t.Errorf("reservations = %v, want %d released", counts, testStock)
+		}
+	})
+}
+
+// TestExpireReservationsReturnsStockOnce runs the expiry job several times at
+// once, alone and while the buyers place their orders, and checks that every
+// reserved piece is either sold or given back exactly once.
+func TestExpireReservationsReturnsStockOnce(t *testing.T) {
+	conn := testDB(t)
+	db := &AppHandler{DB: conn}
+	const jobs = 4
+	expire := func(t *testing.T) {
+		if _, err := db.ExpireReservations(time.Now().Add(reservationTTL + time.Minute)); err != nil {
+			t.Errorf("ExpireReservations: %v", err)
+		}
+	}
+
+	forEachProduct(t, func(t *testing.T, variant bool) {
+		t.Run("alone", func(t *testing.T) {
+			f := newStockFixture(t, conn, testStock, testBuyers, variant)
+			reserved := concurrently(f.buyerIDs, expect(t, db.BeginCheckout(), http.MethodPost, "/checkout", "", http.StatusCreated, http.StatusBadRequest))
+			if len(reserved) != testStock {
+				t.Fatalf("%d checkouts succeeded, want %d", len(reserved), testStock)
+			}
+
+			concurrently(make([]int, jobs), func(int) bool {
+				expire(t)
+				return false
+			})
+			f.checkQuantities(t, conn, testStock)
+			if counts := f.reservations(t, conn); counts["expired"] != testStock || counts["active"] != 0 {
+				t.Errorf("reservations = %v, want %d expired", counts, testStock)
+			}
+
+			expire(t)
+			f.checkQuantities(t, conn, testStock)
+		})
+
+		t.Run("with orders", func(t *testing.T) {
+			f := newStockFixture(t, conn, testStock, testBuyers, variant)
+			reserved := concurrently(f.buyerIDs, expect(t, db.BeginCheckout(), http.MethodPost, "/checkout", "", http.StatusCreated, http.StatusBadRequest))
+			if len(reserved) != testStock {
+				t.Fatalf("%d checkouts succeeded, want %d", len(reserved), testStock)
+			}
+
+			// Kullanıcı ID'leri sipariş verir, sıfırlar süre dolumu işini çalıştırır
+			order := expect(t, db.CreateOrder(), http.MethodPost, "/order", "{}", http.StatusCreated, http.StatusBadRequest)
+			stop := f.watch(conn)
+			ordered := concurrently(append(make([]int, jobs), reserved...), func(userID int) bool {
+				if userID == 0 {
+					expire(t)
+					return false
+				}
+				return order(userID)
+			})
+			if err := stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			f.checkQuantities(t, conn, testStock-len(ordered))
+			if counts := f.reservations(t, conn); counts["active"] != 0 || counts["expired"]+counts["completed"] != testStock {
+				t.Errorf("reservations = %v, want %d expired or completed", counts, testStock)
+			}
+		})
+	})
+}
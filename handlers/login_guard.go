@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	freeLoginAttempts       = 3
+	maxLoginDelay           = 30 * time.Second
+	accountLockoutThreshold = 10
+	ipLockoutThreshold      = 50
+	lockoutDuration         = 15 * time.Minute
+
+	scopeAccount = "account"
+	scopeIP      = "ip"
+)
+
+// dummyHash is compared against when the email does not exist so that both
+// failure paths take roughly the same time.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), bcrypt.DefaultCost)
+
+// clientIP returns the remote address of the request without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginDelay returns how long to wait after the given number of consecutive failures.
+func loginDelay(failures int) time.Duration {
+	if failures <= freeLoginAttempts {
+		return 0
+	}
+	delay := time.Second << uint(failures-freeLoginAttempts-1)
+	if delay > maxLoginDelay || delay <= 0 {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// loginRetryAfter returns how long the caller has to wait before another login
+// attempt for the email or from the IP is accepted; zero means it is allowed now.
+func (db *AppHandler) loginRetryAfter(email, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range [][2]string{{scopeAccount, normalizeEmail(email)}, {scopeIP, ip}} {
+		var failures int
+		var lastFailureAt time.Time
+		var lockedUntil sql.NullTime
+		err := db.DB.QueryRow("SELECT failures, last_failure_at, locked_until FROM login_failures WHERE scope = ? AND identifier = ?", key[0], key[1]).
+			Scan(&failures, &lastFailureAt, &lockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if lockedUntil.Valid && lockedUntil.Time.After(now) {
+			if d := lockedUntil.Time.Sub(now); d > wait {
+				wait = d
+			}
+		}
+		// IP için kademeli bekleme uygulanmaz, yalnızca kilitlenir
+		if key[0] == scopeAccount {
+			if d := lastFailureAt.Add(loginDelay(failures)).Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed attempt for the email and the IP and locks
+// either one once its threshold is reached. Counters reset after a quiet period.
+func (db *AppHandler) recordLoginFailure(email, ip string) error {
+	now := time.Now()
+	keys := []struct {
+		scope, identifier string
+		threshold         int
+	}{
+		{scopeAccount, normalizeEmail(email), accountLockoutThreshold},
+		{scopeIP, ip, ipLockoutThreshold},
+	}
+
+	for _, key := range keys {
+		_, err := db.DB.Exec(`INSERT INTO login_failures (scope, identifier, failures, last_failure_at) VALUES (?, ?, 1, ?)
+			ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = VALUES(last_failure_at)`,
+			key.scope, key.identifier, now, now.Add(-lockoutDuration))
+		if err != nil {
+			return err
+		}
+
+		_, err = db.DB.Exec("UPDATE login_failures SET locked_until = ? WHERE scope = ? AND identifier = ? AND failures >= ?",
+			now.Add(lockoutDuration), key.scope, key.identifier, key.threshold)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures resets the account counter after a successful login.
+func (db *AppHandler) clearLoginFailures(email string) error {
+	_, err := db.DB.Exec("DELETE FROM login_failures WHERE scope = ? AND identifier = ?", scopeAccount, normalizeEmail(email))
+	return err
+}
+
+// tooManyAttempts writes a 429 response with a Retry-After header.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(wait.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
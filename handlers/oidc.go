@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"e-ticaret-api/oidc"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// errOIDCAccountNotVerified is returned when a provider identity matches an
+// account whose email was never verified; linking it could hand the account of
+// whoever registered the address to the provider user.
+var errOIDCAccountNotVerified = errors.New("account with this email is not verified")
+
+// OIDCLogin godoc
+// @Summary Start social login
+// @Description Redirect to the OpenID Connect provider. The authorization code flow uses PKCE, state and nonce.
+// @Tags auth
+// @Param   provider    path   string  true   "Provider name"
+// @Param   login_hint  query  string  false  "Email hint passed to the provider"
+// @Success 302 {string} string "Redirect to provider"
+// @Failure 404 {string} string "Unknown provider"
+// @Failure 502 {string} string "Provider unavailable"
+// @Router /auth/{provider}/login [get]
+func (db *AppHandler) OIDCLogin() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["provider"]
+		provider, ok := db.OIDCProviders[name]
+		if !ok {
+			http.Error(w, "Unknown provider", http.StatusNotFound)
+			return
+		}
+
+		state, err := oidc.RandomString(24)
+		if err != nil {
+			http.Error(w, "Error creating state", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := oidc.RandomString(24)
+		if err != nil {
+			http.Error(w, "Error creating nonce", http.StatusInternalServerError)
+			return
+		}
+		verifier, challenge, err := oidc.NewPKCE()
+		if err != nil {
+			http.Error(w, "Error creating code verifier", http.StatusInternalServerError)
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge, r.URL.Query().Get("login_hint"))
+		if err != nil {
+			log.Println("OIDC discovery error: ", err)
+			http.Error(w, "Provider unavailable", http.StatusBadGateway)
+			return
+		}
+
+		// Verifier ve nonce sunucuda saklanır, istemciye sadece state gider
+		_, err = db.DB.Exec("INSERT INTO oidc_states (state, provider, code_verifier, nonce, expires_at) VALUES (?, ?, ?, ?, ?)",
+			hashToken(state), name, verifier, nonce, time.Now().Add(oidcStateTTL))
+		if err != nil {
+			http.Error(w, "Error saving state", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	})
+}
+
+// OIDCCallback godoc
+// @Summary Finish social login
+// @Description Redeem the authorization code returned by the provider and log in. The account is found by the
+// @Description provider identity, then by verified email (the identity is linked), otherwise a customer account is created.
+// @Tags auth
+// @Produce  json
+// @Param   provider  path   string  true  "Provider name"
+// @Param   code      query  string  true  "Authorization code"
+// @Param   state     query  string  true  "State"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid or expired state"
+// @Failure 401 {string} string "Provider login failed"
+// @Failure 403 {string} string "Email not verified by provider"
+// @Failure 409 {string} string "Account not verified"
+// @Router /auth/{provider}/callback [get]
+func (db *AppHandler) OIDCCallback() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["provider"]
+		provider, ok := db.OIDCProviders[name]
+		if !ok {
+			http.Error(w, "Unknown provider", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		if errCode := query.Get("error"); errCode != "" {
+			http.Error(w, "Provider returned error: "+errCode, http.StatusUnauthorized)
+			return
+		}
+		code, state := query.Get("code"), query.Get("state")
+		if code == "" || state == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		verifier, nonce, err := db.consumeOIDCState(name, state)
+		if err != nil {
+			http.Error(w, "Invalid or expired state", http.StatusBadRequest)
+			return
+		}
+
+		identity, err := provider.Exchange(r.Context(), code, verifier, nonce)
+		if err != nil {
+			log.Println("OIDC exchange error: ", err)
+			http.Error(w, "Provider login failed", http.StatusUnauthorized)
+			return
+		}
+		// Doğrulanmamış e-posta ile hesap bağlamak hesap ele geçirmeye açık olur
+		if identity.Email == "" || !identity.EmailVerified {
+			http.Error(w, "Email not verified by provider", http.StatusForbidden)
+			return
+		}
+
+		userID, err := db.resolveOIDCUser(name, identity)
+		if err == errOIDCAccountNotVerified {
+			http.Error(w, "An account with this email exists but is not verified. Log in with your password and verify your email first.", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Println("Error resolving social login user: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, name, role, suspended, totp_enabled FROM users WHERE id = ?", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Suspended, &user.TwoFactorEnabled); err != nil {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		db.completeLogin(w, r, user)
+	})
+}
+
+// consumeOIDCState loads and deletes a pending login so each state is used once.
+func (db *AppHandler) consumeOIDCState(provider, state string) (verifier, nonce string, err error) {
+	var storedProvider string
+	var expiresAt time.Time
+	row := db.DB.QueryRow("SELECT provider, code_verifier, nonce, expires_at FROM oidc_states WHERE state = ?", hashToken(state))
+	if err := row.Scan(&storedProvider, &verifier, &nonce, &expiresAt); err != nil {
+		return "", "", err
+	}
+
+	res, err := db.DB.Exec("DELETE FROM oidc_states WHERE state = ?", hashToken(state))
+	if err != nil {
+		return "", "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", "", sql.ErrNoRows
+	}
+	if storedProvider != provider || time.Now().After(expiresAt) {
+		return "", "", sql.ErrNoRows
+	}
+	return verifier, nonce, nil
+}
+
+// resolveOIDCUser returns the user for a provider identity. An unknown identity is
+// linked to the verified account with the same email, or a new customer account is
+// created. errOIDCAccountNotVerified is returned when the account is not verified.
+func (db *AppHandler) resolveOIDCUser(provider string, identity *oidc.Identity) (int, error) {
+	var userID int
+	err := db.DB.QueryRow("SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, identity.Subject).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var verified bool
+	err = tx.QueryRow("SELECT id, verified FROM users WHERE email = ? AND deleted_at IS NULL FOR UPDATE", identity.Email).Scan(&userID, &verified)
+	switch {
+	case err == sql.ErrNoRows:
+		name := identity.Name
+		if name == "" {
+			name = identity.Email
+		}
+		// Sosyal girişle açılan hesabın şifresi yoktur; istenirse şifre sıfırlama ile belirlenir
+		res, err := tx.Exec("INSERT INTO users (email, password, name, role, verified) VALUES (?, ?, ?, ?, ?)", identity.Email, "", name, "customer", true)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		userID = int(id)
+	case err != nil:
+		tx.Rollback()
+		return 0, err
+	case !verified:
+		// Doğrulanmamış hesap başkası tarafından açılmış olabilir; şifresi çalışmaya devam edeceği için bağlanmaz
+		tx.Rollback()
+		return 0, errOIDCAccountNotVerified
+	}
+
+	_, err = tx.Exec("INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, provider, identity.Subject, identity.Email, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
@@ -1,46 +1,99 @@
 package handlers
 
 import (
+	"database/sql"
 	"e-ticaret-api/models"
 	"encoding/json"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// orderAddress returns the user's address with the given ID, or the address
+// flagged by defaultColumn when addressID is zero.
+func (db *AppHandler) orderAddress(userID, addressID int, defaultColumn string) (models.Address, error) {
+	var address models.Address
+	var row *sql.Row
+	if addressID != 0 {
+		row = db.DB.QueryRow("SELECT "+addressColumns+" FROM addresses WHERE id = ? AND user_id = ?", addressID, userID)
+	} else {
+		row = db.DB.QueryRow("SELECT "+addressColumns+" FROM addresses WHERE user_id = ? AND "+defaultColumn+" = ?", userID, true)
+	}
+	err := scanAddress(row, &address)
+	return address, err
+}
+
+// currentPrice returns the price an item sells at now: the price of its variant
+// or, without one, of its product. It is a locking read so that the latest
+// price is seen; sql.ErrNoRows is returned for a deleted variant.
+func currentPrice(tx *sql.Tx, productID int, variantID *int) (float64, error) {
+	var price float64
+	var err error
+	if variantID != nil {
+		err = tx.QueryRow("SELECT price FROM product_variants WHERE id = ? AND product_id = ? FOR UPDATE", *variantID, productID).Scan(&price)
+	} else {
+		err = tx.QueryRow("SELECT price FROM products WHERE id = ? FOR UPDATE", productID).Scan(&price)
+	}
+	return price, err
+}
+
 // CreateOrder godoc
 // @Summary Create an order
-// @Description Create an order for the authenticated user
+// @Description Create an order for the authenticated user, shipped to the given or default address. Stock reserved
+// @Description by POST /checkout is used for the order; without a reservation the stock is taken when the order is placed.
+// @Description Items are charged at the current price of their product or variant.
 // @Tags orders
+// @Accept  json
 // @Produce  json
+// @Param   body  body  object  false  "{\"shipping_address_id\": 1, \"billing_address_id\": 2}"
 // @Success 201 {object} models.Order
 // @Failure 400 {string} string "Bad request"
 // @Failure 404 {string} string "Cart not found"
+// @Failure 409 {string} string "Product is no longer available"
 // @Failure 500 {string} string "Internal server error"
 // @Router /order [post]
 func (db *AppHandler) CreateOrder() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(int)
 
-		var cartID int
-		err := db.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+		var req struct {
+			ShippingAddressID int `json:"shipping_address_id"`
+			BillingAddressID  int `json:"billing_address_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		shippingAddress, err := db.orderAddress(userID, req.ShippingAddressID, "is_default_shipping")
 		if err != nil {
-			http.Error(w, "Cart not found", http.StatusNotFound)
+			http.Error(w, "Shipping address not found", http.StatusBadRequest)
 			return
 		}
+		billingAddress, err := db.orderAddress(userID, req.BillingAddressID, "is_default_billing")
+		if err != nil {
+			if req.BillingAddressID != 0 {
+				http.Error(w, "Billing address not found", http.StatusBadRequest)
+				return
+			}
+			billingAddress = shippingAddress
+		}
 
-		var totalPrice float64
-		err = db.DB.QueryRow("SELECT SUM(price * quantity) FROM cart_items WHERE cart_id = ?", cartID).Scan(&totalPrice)
+		var cartID int
+		err = db.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
 		if err != nil {
-			http.Error(w, "Error calculating total price", http.StatusInternalServerError)
+			http.Error(w, "Cart not found", http.StatusNotFound)
 			return
 		}
 
 		order := models.Order{
-			UserID:     userID,
-			TotalPrice: totalPrice,
-			CreatedAt:  time.Now(),
+			UserID:            userID,
+			CreatedAt:         time.Now(),
+			ShippingAddressID: shippingAddress.ID,
+			ShippingAddress:   formatAddress(shippingAddress),
+			BillingAddress:    formatAddress(billingAddress),
 		}
 
 		tx, err := db.DB.Begin()
@@ -49,23 +102,16 @@ func (db *AppHandler) CreateOrder() http.Handler {
 			return
 		}
 
-		res, err := tx.Exec("INSERT INTO orders (user_id, total_price, created_at) VALUES (?, ?, ?)", order.UserID, order.TotalPrice, order.CreatedAt)
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, "Error inserting order", http.StatusInternalServerError)
-			return
-		}
-
-		lastInsertID, err := res.LastInsertId()
-		if err != nil {
+		// Sepet satırı kilitlenir; aynı kullanıcının ödeme adımları ve siparişleri sırayla işlenir
+		if err := tx.QueryRow("SELECT id FROM carts WHERE id = ? FOR UPDATE", cartID).Scan(&cartID); err != nil {
 			tx.Rollback()
-			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			http.Error(w, "Cart not found", http.StatusNotFound)
 			return
 		}
 
-		order.ID = int(lastInsertID)
-
-		rows, err := tx.Query("SELECT product_id, quantity, price FROM cart_items WHERE cart_id = ?", cartID)
+		rows, err := tx.Query(`SELECT ci.product_id, ci.variant_id, COALESCE(v.sku, ''), ci.quantity, p.archived_at IS NOT NULL FROM cart_items ci
+			JOIN products p ON p.id = ci.product_id
+			LEFT JOIN product_variants v ON v.id = ci.variant_id WHERE ci.cart_id = ?`, cartID)
 		if err != nil {
 			tx.Rollback()
 			http.Error(w, "Error fetching cart items", http.StatusInternalServerError)
@@ -76,45 +122,98 @@ func (db *AppHandler) CreateOrder() http.Handler {
 		var orderItems []models.OrderItem
 		for rows.Next() {
 			var orderItem models.OrderItem
-			err := rows.Scan(&orderItem.ProductID, &orderItem.Quantity, &orderItem.Price)
+			var archived bool
+			err := rows.Scan(&orderItem.ProductID, &orderItem.VariantID, &orderItem.SKU, &orderItem.Quantity, &archived)
 			if err != nil {
 				tx.Rollback()
 				http.Error(w, "Error scanning cart item", http.StatusInternalServerError)
 				return
 			}
-			orderItem.OrderID = order.ID
+			// Arşivlenen ürünler sepetten kaldırılır; bu kontrol aynı anda verilen siparişler içindir
+			if archived {
+				tx.Rollback()
+				http.Error(w, "Product is no longer available", http.StatusConflict)
+				return
+			}
 			orderItems = append(orderItems, orderItem)
 		}
+		if len(orderItems) == 0 {
+			tx.Rollback()
+			http.Error(w, "Cart is empty", http.StatusBadRequest)
+			return
+		}
 
-		for _, orderItem := range orderItems {
-			_, err = tx.Exec("INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)", orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price)
+		// Stok satırları kilitlenir; fiyatlar ve stok bu kilit altında okunup değiştirilir
+		stock := make([]models.StockReservationItem, len(orderItems))
+		for i, orderItem := range orderItems {
+			stock[i] = models.StockReservationItem{ProductID: orderItem.ProductID, VariantID: orderItem.VariantID, Quantity: orderItem.Quantity}
+		}
+		sortStockItems(stock)
+		if err := lockStock(tx, userID, stock); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error reserving stock", http.StatusInternalServerError)
+			return
+		}
+
+		// Sepete eklendiği andaki fiyat değil, kilitli satırlardaki güncel fiyat uygulanır
+		for i := range orderItems {
+			orderItems[i].Price, err = currentPrice(tx, orderItems[i].ProductID, orderItems[i].VariantID)
+			if err == sql.ErrNoRows {
+				tx.Rollback()
+				http.Error(w, "Product is no longer available", http.StatusConflict)
+				return
+			}
 			if err != nil {
 				tx.Rollback()
-				http.Error(w, "Error inserting order item", http.StatusInternalServerError)
+				http.Error(w, "Error fetching product price", http.StatusInternalServerError)
 				return
 			}
+			order.TotalPrice += orderItems[i].Price * float64(orderItems[i].Quantity)
+		}
+
+		res, err := tx.Exec("INSERT INTO orders (user_id, total_price, created_at, shipping_address_id, shipping_address, billing_address) VALUES (?, ?, ?, ?, ?, ?)",
+			order.UserID, order.TotalPrice, order.CreatedAt, order.ShippingAddressID, order.ShippingAddress, order.BillingAddress)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error inserting order", http.StatusInternalServerError)
+			return
 		}
 
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+
+		order.ID = int(lastInsertID)
+
 		for _, orderItem := range orderItems {
-			var existQuantity int
-			err = db.DB.QueryRow("SELECT quantity FROM products WHERE id = ?", orderItem.ProductID).Scan(&existQuantity)
+			_, err = tx.Exec("INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, price) VALUES (?, ?, ?, ?, ?, ?)",
+				order.ID, orderItem.ProductID, orderItem.VariantID, orderItem.SKU, orderItem.Quantity, orderItem.Price)
 			if err != nil {
 				tx.Rollback()
-				http.Error(w, "Error fetching product quantity", http.StatusInternalServerError)
+				http.Error(w, "Error inserting order item", http.StatusInternalServerError)
 				return
 			}
-			if existQuantity < orderItem.Quantity {
+		}
+
+		// Ödeme başlatıldıysa ayrılan stok iade edilip sepetteki ürünler aynı işlemde yeniden düşülür
+		if _, err := releaseUserReservations(tx, userID, "completed"); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error releasing stock reservation", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range stock {
+			err := takeStock(tx, item)
+			if err == errInsufficientStock || err == errInvalidQuantity {
 				tx.Rollback()
 				http.Error(w, "Not enough product quantity", http.StatusBadRequest)
 				return
 			}
-		}
-
-		for _, orderItem := range orderItems {
-			_, err = tx.Exec("UPDATE products SET quantity = quantity - ? WHERE id = ?", orderItem.Quantity, orderItem.ProductID)
 			if err != nil {
 				tx.Rollback()
-				http.Error(w, "Error inserting order item", http.StatusInternalServerError)
+				http.Error(w, "Error updating product stock", http.StatusInternalServerError)
 				return
 			}
 		}
@@ -140,18 +239,36 @@ func (db *AppHandler) CreateOrder() http.Handler {
 
 // GetOrders godoc
 // @Summary Get all orders
-// @Description Get all orders for the authenticated user
+// @Description Get a page of orders for the authenticated user, newest first by default
 // @Tags orders
 // @Produce  json
-// @Success 200 {array} models.Order
+// @Param sort_by query string false "Sort by: id or total_price (default id)"
+// @Param order query string false "Order (asc or desc, default desc)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.Order}
+// @Failure 400 {string} string "Invalid pagination parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Router /orders [get]
 func (db *AppHandler) GetOrders() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(int)
 
-		var orders []models.Order
-		rows, err := db.DB.Query("SELECT id, user_id, total_price, created_at FROM orders WHERE user_id = ?", userID)
+		page, err := newPageRequest(r, orderSorts, "id", true)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", userID).Scan(&total); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		orders := []models.Order{}
+		query, args := page.keyset("SELECT id, user_id, total_price, created_at, COALESCE(shipping_address, ''), COALESCE(billing_address, '') FROM orders WHERE user_id = ?", []interface{}{userID}, "id")
+		rows, err := db.DB.Query(query, args...)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -160,7 +277,7 @@ func (db *AppHandler) GetOrders() http.Handler {
 
 		for rows.Next() {
 			var order models.Order
-			if err := rows.Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.CreatedAt); err != nil {
+			if err := rows.Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.CreatedAt, &order.ShippingAddress, &order.BillingAddress); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -168,10 +285,28 @@ func (db *AppHandler) GetOrders() http.Handler {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(orders)
+		json.NewEncoder(w).Encode(orderPage(page, orders, total))
 	})
 }
 
+// orderSorts are the sort_by values accepted by the order listings.
+var orderSorts = map[string]string{"id": "id", "total_price": "total_price"}
+
+// orderPage wraps a page of orders read with one extra row into the list envelope.
+func orderPage(page pageRequest, orders []models.Order, total int) models.Page {
+	next := ""
+	if len(orders) > page.limit {
+		orders = orders[:page.limit]
+		last := orders[page.limit-1]
+		var value interface{} = last.ID
+		if page.sortBy == "total_price" {
+			value = last.TotalPrice
+		}
+		next = page.cursor(value, last.ID)
+	}
+	return models.Page{Items: orders, NextCursor: next, Total: total, Limit: page.limit}
+}
+
 // GetOrderItems godoc
 // @Summary Get all items for a specific order
 // @Description Get all items for a specific order
@@ -188,7 +323,7 @@ func (db *AppHandler) GetOrderItems() http.Handler {
 
 		var orderItems []models.OrderItem
 
-		rows, err := db.DB.Query("SELECT id, order_id, product_id, quantity, price FROM order_items WHERE order_id = ?", orderID)
+		rows, err := db.DB.Query("SELECT id, order_id, product_id, variant_id, sku, quantity, price FROM order_items WHERE order_id = ?", orderID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -197,7 +332,7 @@ func (db *AppHandler) GetOrderItems() http.Handler {
 
 		for rows.Next() {
 			var orderItem models.OrderItem
-			if err := rows.Scan(&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity, &orderItem.Price); err != nil {
+			if err := rows.Scan(&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.VariantID, &orderItem.SKU, &orderItem.Quantity, &orderItem.Price); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -250,3 +385,43 @@ func (db *AppHandler) UpdateOrderStatus() http.Handler {
 		json.NewEncoder(w).Encode(map[string]string{"message": "Order status updated"})
 	})
 }
+
+// GetSellerOrders godoc
+// @Summary Get orders for the seller's products
+// @Description Get ordered items of the authenticated seller's products with their order status
+// @Tags seller
+// @Produce  json
+// @Success 200 {array} models.SellerOrderItem
+// @Failure 500 {string} string "Internal server error"
+// @Router /seller/orders [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetSellerOrders() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		rows, err := db.DB.Query(`SELECT oi.id, oi.order_id, oi.product_id, oi.variant_id, oi.sku, oi.quantity, oi.price, o.status, o.created_at
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			JOIN products p ON p.id = oi.product_id
+			WHERE p.seller_id = ?
+			ORDER BY o.created_at DESC`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var items []models.SellerOrderItem
+		for rows.Next() {
+			var item models.SellerOrderItem
+			if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.Price, &item.Status, &item.CreatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			items = append(items, item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	})
+}
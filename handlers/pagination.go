@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var errInvalidPage = errors.New("invalid pagination parameters")
+
+// pageCursor is the position after the last row of a page. It is sent to
+// clients as opaque base64url JSON and is bound to the sort it was made for.
+type pageCursor struct {
+	SortBy string      `json:"s"`
+	Desc   bool        `json:"d"`
+	Value  interface{} `json:"v"`
+	ID     int         `json:"id"`
+}
+
+// pageRequest is a keyset pagination request read from the limit, cursor,
+// sort_by and order query parameters.
+type pageRequest struct {
+	limit  int
+	sortBy string
+	column string
+	desc   bool
+	after  *pageCursor
+}
+
+// newPageRequest reads pagination parameters. sorts maps the allowed sort_by
+// values to their SQL columns; anything else is rejected, so column names never
+// come from the request.
+func newPageRequest(r *http.Request, sorts map[string]string, defaultSort string, defaultDesc bool) (pageRequest, error) {
+	query := r.URL.Query()
+	page := pageRequest{limit: defaultPageLimit, sortBy: defaultSort, desc: defaultDesc}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, errInvalidPage
+		}
+		page.limit = limit
+	}
+
+	if value := query.Get("sort_by"); value != "" {
+		page.sortBy = value
+	}
+	column, ok := sorts[page.sortBy]
+	if !ok {
+		return page, errInvalidPage
+	}
+	page.column = column
+
+	switch query.Get("order") {
+	case "":
+	case "asc", "ASC":
+		page.desc = false
+	case "desc", "DESC":
+		page.desc = true
+	default:
+		return page, errInvalidPage
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return page, errInvalidPage
+		}
+		var cursor pageCursor
+		if err := json.Unmarshal(raw, &cursor); err != nil || cursor.SortBy != page.sortBy || cursor.Desc != page.desc {
+			return page, errInvalidPage
+		}
+		page.after = &cursor
+	}
+	return page, nil
+}
+
+// keyset appends the cursor condition, ORDER BY and LIMIT to query, whose WHERE
+// clause must already be open. The id column breaks ties between equal sort
+// values. One row more than the limit is requested to tell whether a next page exists.
+func (p pageRequest) keyset(query string, args []interface{}, idColumn string) (string, []interface{}) {
+	cmp, dir := ">", "ASC"
+	if p.desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if p.after != nil {
+		if p.column == idColumn {
+			query += " AND " + idColumn + " " + cmp + " ?"
+			args = append(args, p.after.ID)
+		} else {
+			query += " AND (" + p.column + " " + cmp + " ? OR (" + p.column + " = ? AND " + idColumn + " " + cmp + " ?))"
+			args = append(args, p.after.Value, p.after.Value, p.after.ID)
+		}
+	}
+
+	if p.column == idColumn {
+		query += " ORDER BY " + idColumn + " " + dir
+	} else {
+		query += " ORDER BY " + p.column + " " + dir + ", " + idColumn + " " + dir
+	}
+	query += " LIMIT ?"
+	args = append(args, p.limit+1)
+	return query, args
+}
+
+// cursor encodes the position after the row with the given sort value and id.
+func (p pageRequest) cursor(value interface{}, id int) string {
+	raw, _ := json.Marshal(pageCursor{SortBy: p.sortBy, Desc: p.desc, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
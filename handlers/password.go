@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL  = time.Hour
+	minPasswordLength = 8
+)
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Send a single-use password reset token to the given email address
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"email\": \"user@example.com\"}"
+// @Success 200 {string} string "If the email exists, a reset token has been sent"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 500 {string} string "Internal server error"
+// @Router /password/forgot [post]
+func (db *AppHandler) ForgotPassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		// E-posta kayıtlı olsun olmasın aynı cevap dönülür
+		response := map[string]string{"message": "If the email exists, a reset token has been sent"}
+
+		var userID int
+		if err := db.DB.QueryRow("SELECT id FROM users WHERE email = ?", req.Email).Scan(&userID); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		token, err := randomToken(32)
+		if err != nil {
+			http.Error(w, "Error generating reset token", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		// Önceki kullanılmamış tokenlar geçersiz kılınır
+		_, err = tx.Exec("UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error creating reset token", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)",
+			userID, hashToken(token), now.Add(passwordResetTTL), now)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error creating reset token", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		body := fmt.Sprintf("Şifre sıfırlama kodunuz: %s\n\nBu kod %d dakika geçerlidir ve yalnızca bir kez kullanılabilir.", token, int(passwordResetTTL.Minutes()))
+		if err := db.Mailer.Send(req.Email, "Şifre sıfırlama", body); err != nil {
+			log.Println("Error sending password reset email: ", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password using a password reset token
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"token\": \"...\", \"password\": \"...\"}"
+// @Success 200 {string} string "Password updated"
+// @Failure 400 {string} string "Invalid or expired token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /password/reset [post]
+func (db *AppHandler) ResetPassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if len(req.Password) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "Error hashing password", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var resetID, userID int
+		err = tx.QueryRow("SELECT id, user_id FROM password_resets WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), now).Scan(&resetID, &userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+
+		res, err := tx.Exec("UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL", now, resetID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error consuming reset token", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE users SET password = ? WHERE id = ?", string(hashedPassword), userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating password", http.StatusInternalServerError)
+			return
+		}
+
+		// Şifre değişince açık oturumlar kapatılır
+		if err := revokeSessions(tx, userID, "", now); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password updated"})
+	})
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// lowestPriceWindow is the period of the lowest price shown next to discounts.
+	lowestPriceWindow = 30 * 24 * time.Hour
+	// priceScheduleInterval is how often due price schedules are applied.
+	priceScheduleInterval = time.Minute
+)
+
+// recordProductPrice adds the current price of a product to its price history
+// unless it equals the last recorded price. It is called after every statement
+// that may change products.price, in the same transaction.
+func recordProductPrice(tx *sql.Tx, productID int, source string, now time.Time) error {
+	_, err := tx.Exec(`INSERT INTO product_price_history (product_id, price, source, changed_at)
+		SELECT p.id, p.price, ?, ? FROM products p WHERE p.id = ?
+		AND NOT (p.price <=> (SELECT h.price FROM product_price_history h WHERE h.product_id = p.id ORDER BY h.id DESC LIMIT 1))`,
+		source, now, productID)
+	return err
+}
+
+// BackfillPriceHistory records the current price of products that have no price
+// history yet, such as products added before prices were recorded. It is called
+// once at startup.
+func (db *AppHandler) BackfillPriceHistory() error {
+	_, err := db.DB.Exec(`INSERT INTO product_price_history (product_id, price, source, changed_at)
+		SELECT p.id, p.price, 'initial', ? FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM product_price_history h WHERE h.product_id = p.id)`, time.Now())
+	return err
+}
+
+// lowestRecentPrice returns the lowest price of a product in the last 30 days:
+// the prices set in that period, the price in effect when it began and the
+// current price.
+func (db *AppHandler) lowestRecentPrice(productID int, current float64) (float64, error) {
+	since := time.Now().Add(-lowestPriceWindow)
+	var lowest sql.NullFloat64
+	err := db.DB.QueryRow(`SELECT MIN(price) FROM product_price_history WHERE product_id = ? AND (changed_at >= ?
+		OR id = (SELECT id FROM product_price_history WHERE product_id = ? AND changed_at < ? ORDER BY id DESC LIMIT 1))`,
+		productID, since, productID, since).Scan(&lowest)
+	if err != nil {
+		return 0, err
+	}
+	if lowest.Valid && lowest.Float64 < current {
+		return lowest.Float64, nil
+	}
+	return current, nil
+}
+
+// GetPriceHistory godoc
+// @Summary Get the price history of a product
+// @Description Get every price change of a product, newest first, with the lowest price of the last 30 days
+// @Description (including the current price) that discounts have to be compared against. Products sold in
+// @Description variants record their lowest variant price.
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Success 200 {object} models.PriceHistory
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /products/{id}/price-history [get]
+func (db *AppHandler) GetPriceHistory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		history := models.PriceHistory{Changes: []models.PriceChange{}}
+		err := db.DB.QueryRow("SELECT id, price FROM products WHERE id = ?", mux.Vars(r)["id"]).Scan(&history.ProductID, &history.Price)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if history.LowestPrice30Days, err = db.lowestRecentPrice(history.ProductID, history.Price); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.DB.Query("SELECT price, source, changed_at FROM product_price_history WHERE product_id = ? ORDER BY id DESC", history.ProductID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var change models.PriceChange
+			if err := rows.Scan(&change.Price, &change.Source, &change.ChangedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			history.Changes = append(history.Changes, change)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	})
+}
+
+// priceScheduleColumns are the product_price_schedules columns read by scanPriceSchedule.
+const priceScheduleColumns = "id, product_id, price, starts_at, ends_at, previous_price, status, created_at"
+
+// scanPriceSchedule scans a row selected with priceScheduleColumns into schedule.
+func scanPriceSchedule(row rowScanner, schedule *models.PriceSchedule) error {
+	return row.Scan(&schedule.ID, &schedule.ProductID, &schedule.Price, &schedule.StartsAt, &schedule.EndsAt, &schedule.PreviousPrice, &schedule.Status, &schedule.CreatedAt)
+}
+
+// CreatePriceSchedule godoc
+// @Summary Schedule a price change
+// @Description Schedule a future price for one of the seller's products (admins may schedule for any product).
+// @Description With ends_at the change is a campaign: the previous price is restored when it ends, unless the
+// @Description price was changed in the meantime. No other change may start during a campaign. Products
+// @Description sold in variants change their prices on the variants.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param schedule body models.PriceSchedule true "price, starts_at and optional ends_at"
+// @Success 201 {object} models.PriceSchedule
+// @Failure 400 {string} string "Invalid schedule"
+// @Failure 404 {string} string "Product not found"
+// @Failure 409 {string} string "Overlapping schedule"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/price-schedules [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) CreatePriceSchedule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var schedule models.PriceSchedule
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		if schedule.Price < 0 || math.IsNaN(schedule.Price) || !schedule.StartsAt.After(now) ||
+			(schedule.EndsAt != nil && !schedule.EndsAt.After(schedule.StartsAt)) {
+			http.Error(w, "Invalid schedule", http.StatusBadRequest)
+			return
+		}
+
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		variants, err := db.hasVariants(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if variants {
+			http.Error(w, "Varyantlı ürünlerde stok ve fiyat varyantlar üzerinden güncellenir.", http.StatusConflict)
+			return
+		}
+
+		// Kalıcı değişiklikler sırayla uygulanır; yalnızca bir kampanyanın süresi içine başka bir değişiklik düşemez
+		query := `SELECT COUNT(*) FROM product_price_schedules WHERE product_id = ? AND status IN ('scheduled', 'active')
+			AND ends_at IS NOT NULL AND starts_at <= ? AND ends_at > ?`
+		args := []interface{}{product.ID, schedule.StartsAt, schedule.StartsAt}
+		if schedule.EndsAt != nil {
+			query = `SELECT COUNT(*) FROM product_price_schedules WHERE product_id = ? AND status IN ('scheduled', 'active')
+				AND starts_at < ? AND ((ends_at IS NULL AND starts_at >= ?) OR ends_at > ?)`
+			args = []interface{}{product.ID, *schedule.EndsAt, schedule.StartsAt, schedule.StartsAt}
+		}
+		var overlapping int
+		if err := db.DB.QueryRow(query, args...).Scan(&overlapping); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if overlapping > 0 {
+			http.Error(w, "The schedule overlaps a price campaign", http.StatusConflict)
+			return
+		}
+
+		schedule.ProductID = product.ID
+		schedule.PreviousPrice = nil
+		schedule.Status = "scheduled"
+		schedule.CreatedAt = now
+		res, err := db.DB.Exec("INSERT INTO product_price_schedules (product_id, price, starts_at, ends_at, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			schedule.ProductID, schedule.Price, schedule.StartsAt, schedule.EndsAt, schedule.Status, schedule.CreatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		scheduleID, err := res.LastInsertId()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		schedule.ID = int(scheduleID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(schedule)
+	})
+}
+
+// GetPriceSchedules godoc
+// @Summary Get the price schedules of a product
+// @Description Get the scheduled, running and past price changes of one of the seller's products, by start time
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Success 200 {array} models.PriceSchedule
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/price-schedules [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetPriceSchedules() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+
+		rows, err := db.DB.Query("SELECT "+priceScheduleColumns+" FROM product_price_schedules WHERE product_id = ? ORDER BY starts_at, id", product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		schedules := []models.PriceSchedule{}
+		for rows.Next() {
+			var schedule models.PriceSchedule
+			if err := scanPriceSchedule(rows, &schedule); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			schedules = append(schedules, schedule)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedules)
+	})
+}
+
+// CancelPriceSchedule godoc
+// @Summary Cancel a price schedule
+// @Description Cancel a price change of one of the seller's products that has not started yet
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param schedule_id path int true "Schedule ID"
+// @Success 200 {string} string "Schedule cancelled"
+// @Failure 404 {string} string "Schedule not found"
+// @Failure 409 {string} string "Schedule already started"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/price-schedules/{schedule_id} [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) CancelPriceSchedule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		product, ok := db.findOwnedProduct(r, vars["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+
+		res, err := db.DB.Exec("UPDATE product_price_schedules SET status = 'cancelled' WHERE id = ? AND product_id = ? AND status = 'scheduled'",
+			vars["schedule_id"], product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			var status string
+			err := db.DB.QueryRow("SELECT status FROM product_price_schedules WHERE id = ? AND product_id = ?", vars["schedule_id"], product.ID).Scan(&status)
+			if err == sql.ErrNoRows {
+				http.Error(w, "Schedule not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Error(w, "Only schedules that have not started can be cancelled", http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Fiyat planı iptal edildi."})
+	})
+}
+
+// StartPriceScheduler applies due price schedules now and then every minute.
+func (db *AppHandler) StartPriceScheduler() {
+	go func() {
+		ticker := time.NewTicker(priceScheduleInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := db.ApplyScheduledPrices(time.Now()); err != nil {
+				log.Println("Error applying scheduled prices: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ApplyScheduledPrices starts the price schedules due at now and ends the
+// campaigns that are over, in order. It returns the number of schedules changed.
+func (db *AppHandler) ApplyScheduledPrices(now time.Time) (int, error) {
+	rows, err := db.DB.Query(`SELECT id FROM product_price_schedules
+		WHERE (status = 'scheduled' AND starts_at <= ?) OR (status = 'active' AND ends_at <= ?)
+		ORDER BY COALESCE(CASE WHEN status = 'active' THEN ends_at END, starts_at), id`, now, now)
+	if err != nil {
+		return 0, err
+	}
+	var scheduleIDs []int
+	for rows.Next() {
+		var scheduleID int
+		if err := rows.Scan(&scheduleID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		scheduleIDs = append(scheduleIDs, scheduleID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, scheduleID := range scheduleIDs {
+		ok, err := db.applyPriceSchedule(scheduleID, now)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// applyPriceSchedule starts or ends one schedule. The schedule and product rows
+// are locked, so a schedule is applied once even with several API instances.
+func (db *AppHandler) applyPriceSchedule(scheduleID int, now time.Time) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	var schedule models.PriceSchedule
+	if err := scanPriceSchedule(tx.QueryRow("SELECT "+priceScheduleColumns+" FROM product_price_schedules WHERE id = ? FOR UPDATE", scheduleID), &schedule); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var current float64
+	var archived, variants bool
+	err = tx.QueryRow(`SELECT price, archived_at IS NOT NULL, EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+		FROM products p WHERE id = ? FOR UPDATE`, schedule.ProductID).Scan(&current, &archived, &variants)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	status, price := "", current
+	switch {
+	case schedule.Status == "scheduled" && !schedule.StartsAt.After(now):
+		if archived || variants {
+			status = "cancelled"
+			break
+		}
+		status, price = "completed", schedule.Price
+		if schedule.EndsAt != nil && schedule.EndsAt.After(now) {
+			status = "active"
+		} else if schedule.EndsAt != nil {
+			// Kampanya uygulanamadan bittiyse fiyat değiştirilmez
+			price = current
+		}
+	case schedule.Status == "active" && schedule.EndsAt != nil && !schedule.EndsAt.After(now):
+		status = "completed"
+		// Kampanya süresince fiyat elle değiştirildiyse önceki fiyata dönülmez
+		if !archived && !variants && schedule.PreviousPrice != nil && current == schedule.Price {
+			price = *schedule.PreviousPrice
+		}
+	default:
+		tx.Rollback()
+		return false, nil
+	}
+
+	if price != current {
+		if _, err := tx.Exec("UPDATE products SET price = ? WHERE id = ?", price, schedule.ProductID); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if err := recordProductPrice(tx, schedule.ProductID, "scheduled", now); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	query, args := "UPDATE product_price_schedules SET status = ? WHERE id = ?", []interface{}{status, schedule.ID}
+	if schedule.Status == "scheduled" {
+		query, args = "UPDATE product_price_schedules SET status = ?, previous_price = ? WHERE id = ?", []interface{}{status, current, schedule.ID}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// exportQueries lists the files of a personal data export and the query that
+// fills each one. Every query takes the user ID as its only argument.
+var exportQueries = []struct {
+	file  string
+	query string
+}{
+	{"addresses.json", "SELECT " + addressColumns + " FROM addresses WHERE user_id = ?"},
+	{"cart_items.json", "SELECT ci.id, ci.cart_id, ci.product_id, ci.variant_id, ci.quantity, ci.price FROM cart_items ci JOIN carts c ON c.id = ci.cart_id WHERE c.user_id = ?"},
+	{"orders.json", "SELECT id, user_id, total_price, created_at, status, COALESCE(shipping_address, '') AS shipping_address, COALESCE(billing_address, '') AS billing_address FROM orders WHERE user_id = ?"},
+	{"order_items.json", "SELECT oi.id, oi.order_id, oi.product_id, oi.variant_id, oi.sku, oi.quantity, oi.price FROM order_items oi JOIN orders o ON o.id = oi.order_id WHERE o.user_id = ?"},
+	{"returns.json", "SELECT r.id, r.order_id, r.product_id, r.reason, r.status, r.created_at FROM returns r JOIN orders o ON o.id = r.order_id WHERE o.user_id = ?"},
+	{"reviews.json", "SELECT id, product_id, user_id, rating, comment, created_at FROM reviews WHERE user_id = ?"},
+}
+
+// queryMaps runs query and returns every row as a column name to value map.
+func (db *AppHandler) queryMaps(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// MySQL sürücüsü metin kolonları []byte olarak döner
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ExportMyData godoc
+// @Summary Export personal data
+// @Description Download a ZIP archive of JSON files with the user's profile, addresses, cart, orders, order items, returns and reviews
+// @Tags me
+// @Produce  application/zip
+// @Success 200 {file} file "ZIP archive"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/export [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) ExportMyData() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, name, role, verified, suspended, totp_enabled FROM users WHERE id = ?", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Verified, &user.Suspended, &user.TwoFactorEnabled); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		// Yanıt yazılmaya başlamadan önce tüm veriler toplanır ki hata olursa 500 dönülebilsin
+		files := map[string]interface{}{"profile.json": user}
+		for _, export := range exportQueries {
+			data, err := db.queryMaps(export.query, userID)
+			if err != nil {
+				http.Error(w, "Error exporting "+export.file, http.StatusInternalServerError)
+				return
+			}
+			files[export.file] = data
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"data-export-%d-%s.zip\"", userID, time.Now().Format("20060102")))
+
+		archive := zip.NewWriter(w)
+		names := []string{"profile.json"}
+		for _, export := range exportQueries {
+			names = append(names, export.file)
+		}
+		for _, name := range names {
+			f, err := archive.Create(name)
+			if err != nil {
+				log.Println("Error writing export archive: ", err)
+				return
+			}
+			encoder := json.NewEncoder(f)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(files[name]); err != nil {
+				log.Println("Error writing export archive: ", err)
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			log.Println("Error closing export archive: ", err)
+		}
+	})
+}
+
+// DeleteMyAccount godoc
+// @Summary Delete own account
+// @Description Erase the account: the user row and reviews are anonymized, addresses, carts, keys and tokens
+// @Description are deleted, and orders are kept for accounting.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"password\": \"...\"}"
+// @Success 200 {string} string "Account deleted"
+// @Failure 401 {string} string "Password is incorrect"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) DeleteMyAccount() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+		userID := claims.UserID
+
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var email, storedPassword string
+		if err := db.DB.QueryRow("SELECT email, password FROM users WHERE id = ?", userID).Scan(&email, &storedPassword); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(req.Password)); err != nil {
+			http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+			return
+		}
+
+		now := time.Now()
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := anonymizeUser(tx, userID, email, now); err != nil {
+			tx.Rollback()
+			log.Println("Error deleting account: ", err)
+			http.Error(w, "Error deleting account", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)", claims.Id, time.Unix(claims.ExpiresAt, 0))
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error revoking token", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted"})
+	})
+}
+
+// anonymizeUser removes personal data of a user. Orders, order items and returns
+// are kept because they are needed for accounting. Reviews keep their rating but
+// lose their comment and stay attached to the anonymized user row.
+func anonymizeUser(tx *sql.Tx, userID int, email string, now time.Time) error {
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id = ?)", []interface{}{userID}},
+		{"DELETE FROM carts WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM addresses WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM recovery_codes WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM password_resets WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM email_verifications WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM seller_applications WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM user_identities WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM login_failures WHERE scope = ? AND identifier = ?", []interface{}{scopeAccount, normalizeEmail(email)}},
+		{"UPDATE api_keys SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", []interface{}{now, userID}},
+		{"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", []interface{}{now, userID}},
+		{"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", []interface{}{now, userID}},
+		{"UPDATE reviews SET comment = '' WHERE user_id = ?", []interface{}{userID}},
+		{`UPDATE users SET email = ?, name = ?, password = '', verified = ?, suspended = ?, totp_enabled = ?, totp_secret = NULL, totp_last_step = 0, deleted_at = ?
+			WHERE id = ?`, []interface{}{fmt.Sprintf("deleted-%d@deleted.invalid", userID), "Deleted user", false, true, false, now, userID}},
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
 	"e-ticaret-api/models"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -34,90 +43,301 @@ func (db *AppHandler) AddProduct() http.Handler {
 			return
 		}
 
-		res, err := db.DB.Exec("INSERT INTO products (name, description, quantity, price, seller_id, category, image_url) VALUES (?, ?, ?, ?, ?, ?, ?)", product.Name, product.Description, product.Quantity, product.Price, UserID, product.Category, product.ImageURL)
+		category, err := db.categoryName(product.CategoryID)
+		if err == errUnknownCategory {
+			http.Error(w, "Geçersiz kategori.", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		product.Category = category
+
+		product.SKU = strings.TrimSpace(product.SKU)
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		res, err := tx.Exec("INSERT INTO products (name, description, quantity, price, seller_id, sku, category_id, image_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", product.Name, product.Description, product.Quantity, product.Price, UserID, skuValue(product.SKU), product.CategoryID, product.ImageURL)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "Bu SKU başka bir ürününüzde kullanılıyor.", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		lastInsertID, err := res.LastInsertId()
 		if err != nil {
+			tx.Rollback()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if err := recordProductPrice(tx, int(lastInsertID), "initial", time.Now()); err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
 
 		product.ID = int(lastInsertID)
+		product.SellerID = UserID
+		product.ArchivedAt = nil
+		db.indexProduct(product)
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(product)
 	})
 }
 
+// productPatch holds the fields of a product update. Fields left out of the
+// request body stay nil and keep their current value.
+type productPatch struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Quantity    *int     `json:"quantity"`
+	Price       *float64 `json:"price"`
+	SKU         *string  `json:"sku"`
+	CategoryID  *int     `json:"category_id"`
+	ImageURL    *string  `json:"image_url"`
+}
+
+// columns returns the SET assignments and arguments for the fields present
+// in the patch, taking the values from the patched product.
+func (p productPatch) columns(product models.Product) ([]string, []interface{}) {
+	var sets []string
+	var args []interface{}
+	add := func(present bool, column string, value interface{}) {
+		if present {
+			sets = append(sets, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	add(p.Name != nil, "name", product.Name)
+	add(p.Description != nil, "description", product.Description)
+	add(p.Quantity != nil, "quantity", product.Quantity)
+	add(p.Price != nil, "price", product.Price)
+	add(p.SKU != nil, "sku", skuValue(product.SKU))
+	add(p.CategoryID != nil, "category_id", product.CategoryID)
+	add(p.ImageURL != nil, "image_url", product.ImageURL)
+	return sets, args
+}
+
+// apply copies the fields present in the patch onto product.
+func (p productPatch) apply(product *models.Product) {
+	if p.Name != nil {
+		product.Name = *p.Name
+	}
+	if p.Description != nil {
+		product.Description = *p.Description
+	}
+	if p.Quantity != nil {
+		product.Quantity = *p.Quantity
+	}
+	if p.Price != nil {
+		product.Price = *p.Price
+	}
+	if p.SKU != nil {
+		product.SKU = strings.TrimSpace(*p.SKU)
+	}
+	if p.CategoryID != nil {
+		product.CategoryID = *p.CategoryID
+	}
+	if p.ImageURL != nil {
+		product.ImageURL = *p.ImageURL
+	}
+}
+
+// productColumns are the products columns read by scanProduct. The category name
+// is looked up from categories so responses keep showing it.
+const productColumns = "id, name, description, quantity, price, seller_id, COALESCE(sku, ''), COALESCE(category_id, 0), " +
+	"COALESCE((SELECT c.name FROM categories c WHERE c.id = products.category_id), ''), image_url, archived_at"
+
+// scanProduct scans a row selected with productColumns into product.
+func scanProduct(row rowScanner, product *models.Product) error {
+	return row.Scan(&product.ID, &product.Name, &product.Description, &product.Quantity, &product.Price, &product.SellerID, &product.SKU, &product.CategoryID, &product.Category, &product.ImageURL, &product.ArchivedAt)
+}
+
+// skuValue stores an empty SKU as NULL so products without one do not collide
+// on the unique (seller_id, sku) index.
+func skuValue(sku string) interface{} {
+	if sku == "" {
+		return nil
+	}
+	return sku
+}
+
+// findOwnedProduct loads a product the caller may change: sellers only their own
+// products, admins any product. Foreign and archived products are reported as not
+// found so their existence is not revealed.
+func (db *AppHandler) findOwnedProduct(r *http.Request, productID string) (models.Product, bool) {
+	userID := r.Context().Value("userID").(int)
+	role := r.Context().Value("role").(string)
+
+	var product models.Product
+	if err := scanProduct(db.DB.QueryRow("SELECT "+productColumns+" FROM products WHERE id = ? AND archived_at IS NULL", productID), &product); err != nil {
+		return product, false
+	}
+	if role != "admin" && product.SellerID != userID {
+		return product, false
+	}
+	return product, true
+}
+
 // UpdateProduct godoc
 // @Summary Update an existing product
-// @Description Update an existing product by seller
+// @Description Update one of the seller's products (admins may update any product). Only the fields present
+// @Description in the body are changed, for both PUT and PATCH. Stock and price of products sold in variants
+// @Description are changed on the variants. Stock reserved at checkout is subtracted from the given stock.
 // @Tags products
+// @Accept  json
 // @Produce  json
 // @Param id path int true "Product ID"
-// @Param product body models.Product true "Product to update"
-// @Success 204 {object} models.Product
+// @Param product body models.Product true "Fields to update"
+// @Success 200 {object} models.Product
 // @Failure 400 {string} string "Bad request"
 // @Failure 404 {string} string "Product not found"
+// @Failure 409 {string} string "Product has variants or stock is below the reserved quantity"
 // @Failure 500 {string} string "Internal server error"
 // @Router /product/{id} [put]
+// @Router /product/{id} [patch]
 func (db *AppHandler) UpdateProduct() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		productID := vars["id"]
 
-		var product models.Product
-		if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		var patch productPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if (patch.Name != nil && *patch.Name == "") || (patch.Quantity != nil && *patch.Quantity < 0) || (patch.Price != nil && *patch.Price < 0) {
+			http.Error(w, "Geçersiz ürün bilgisi.", http.StatusBadRequest)
+			return
+		}
 
-		var existProduct models.Product
-		row := db.DB.QueryRow("SELECT name, description, quantity, price, seller_id, image_url FROM products WHERE id = ?", productID)
-		if err := row.Scan(&existProduct.Name, &existProduct.Description, &existProduct.Quantity, &existProduct.Price, &existProduct.SellerID, &existProduct.ImageURL); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		product, ok := db.findOwnedProduct(r, productID)
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
 			return
 		}
+		if patch.Quantity != nil || patch.Price != nil {
+			variants, err := db.hasVariants(product.ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if variants {
+				http.Error(w, "Varyantlı ürünlerde stok ve fiyat varyantlar üzerinden güncellenir.", http.StatusConflict)
+				return
+			}
+		}
+		var category string
+		if patch.CategoryID != nil {
+			name, err := db.categoryName(*patch.CategoryID)
+			if err == errUnknownCategory {
+				http.Error(w, "Geçersiz kategori.", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			category = name
+		}
 
-		if product.Name != "" {
-			existProduct.Name = product.Name
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
 		}
-		if product.Description != "" {
-			existProduct.Description = product.Description
+		// Ürün kilitlenip tekrar okunur; zamanlanmış fiyat değişikliği bu arada uygulanmış olabilir
+		err = scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM products WHERE id = ? AND archived_at IS NULL FOR UPDATE", product.ID), &product)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
 		}
-		if product.Quantity != existProduct.Quantity {
-			existProduct.Quantity = product.Quantity
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		if product.Price != existProduct.Price {
-			existProduct.Price = product.Price
+		patch.apply(&product)
+		if patch.CategoryID != nil {
+			product.Category = category
 		}
-		if product.ImageURL != "" {
-			existProduct.ImageURL = product.ImageURL
+		// Ödeme için ayrılan stok iade edildiğinde eklenecektir; girilen stoktan düşülerek saklanır
+		if patch.Quantity != nil {
+			product.Quantity, err = availableStock(tx, product.ID, nil, *patch.Quantity)
+			if err == errStockBelowReserved {
+				tx.Rollback()
+				http.Error(w, "Stok, ödeme için ayrılmış miktarın altına düşürülemez.", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 		}
 
-		_, err := db.DB.Exec("UPDATE products SET name = ?, description = ?, quantity = ?, price = ?, image_url = ? WHERE id = ?", existProduct.Name, existProduct.Description, existProduct.Quantity, existProduct.Price, existProduct.ImageURL, productID)
+		// Yalnızca gönderilen alanlar yazılır; stok ödeme sırasında koşullu olarak düşülür
+		sets, args := patch.columns(product)
+		if len(sets) == 0 {
+			tx.Rollback()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(product)
+			return
+		}
+		_, err = tx.Exec("UPDATE products SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, product.ID)...)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "Bu SKU başka bir ürününüzde kullanılıyor.", http.StatusConflict)
+			return
+		}
 		if err != nil {
+			tx.Rollback()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// Fiyat değiştiyse fiyat geçmişine eklenir
+		if patch.Price != nil {
+			if err := recordProductPrice(tx, product.ID, "manual", time.Now()); err != nil {
+				tx.Rollback()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+		db.indexProduct(product)
 
-		w.WriteHeader(http.StatusNoContent)
-		json.NewEncoder(w).Encode(existProduct)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(product)
 	})
 }
 
 // DeleteProduct godoc
 // @Summary Delete a product
-// @Description Delete a product by seller
+// @Description Archive one of the seller's products (admins may archive any product). Archived products are
+// @Description hidden from listings and search, cannot be bought and are removed from carts, but stay readable
+// @Description for past orders. Admins can restore them; archived products that were never ordered are purged later.
 // @Tags products
 // @Produce  json
 // @Param id path int true "Product ID"
-// @Success 200 {string} string "Product deleted"
-// @Failure 401 {string} string "Unauthorized"
+// @Success 200 {string} string "Product archived"
+// @Failure 404 {string} string "Product not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /product/{id} [delete]
 func (db *AppHandler) DeleteProduct() http.Handler {
@@ -125,82 +345,357 @@ func (db *AppHandler) DeleteProduct() http.Handler {
 		vars := mux.Vars(r)
 		productID := vars["id"]
 
-		UserRole := r.Context().Value("role").(string)
-		if UserRole != "seller" {
-			http.Error(w, "Sadece satıcılar ürün silebilir.", http.StatusUnauthorized)
+		product, ok := db.findOwnedProduct(r, productID)
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
 			return
 		}
 
-		_, err := db.DB.Exec("DELETE FROM products WHERE id = ?", productID)
+		// Sipariş geçmişi ürüne bağlı kaldığı için ürün silinmez, arşivlenir
+		tx, err := db.DB.Begin()
 		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec("UPDATE products SET archived_at = ? WHERE id = ? AND archived_at IS NULL", time.Now(), product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec("DELETE FROM cart_items WHERE product_id = ?", product.ID); err != nil {
+			tx.Rollback()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+		db.unindexProduct(product.ID)
 
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Urun silindi."})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Ürün arşivlendi."})
+	})
+}
+
+const (
+	lowStockThreshold   = 5
+	relatedProductLimit = 6
+)
+
+// stockStatus describes the stock level of a product for storefronts.
+func stockStatus(quantity int) string {
+	switch {
+	case quantity <= 0:
+		return "out_of_stock"
+	case quantity <= lowStockThreshold:
+		return "low_stock"
+	default:
+		return "in_stock"
+	}
+}
+
+// GetProduct godoc
+// @Summary Get a product
+// @Description Get a product with its seller name, average rating, review count, lowest price of the last 30 days, stock status, images,
+// @Description options and variants, and related products from the same category. Responses carry an ETag; send it in If-None-Match to get 304.
+// @Description Archived products are still returned, with archived_at and the stock status discontinued, so past orders can show them.
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Success 200 {object} models.ProductDetail
+// @Success 304 {string} string "Not modified"
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /products/{id} [get]
+func (db *AppHandler) GetProduct() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		productID := vars["id"]
+
+		var detail models.ProductDetail
+		row := db.DB.QueryRow("SELECT "+productColumns+", COALESCE((SELECT u.name FROM users u WHERE u.id = products.seller_id), '') FROM products WHERE id = ?", productID)
+		if err := row.Scan(&detail.ID, &detail.Name, &detail.Description, &detail.Quantity, &detail.Price, &detail.SellerID, &detail.SKU, &detail.CategoryID, &detail.Category, &detail.ImageURL, &detail.ArchivedAt, &detail.SellerName); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Product not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		detail.StockStatus = stockStatus(detail.Quantity)
+		if detail.ArchivedAt != nil {
+			detail.StockStatus = "discontinued"
+		}
+
+		var average float64
+		if err := db.DB.QueryRow("SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM reviews WHERE product_id = ?", detail.ID).Scan(&average, &detail.ReviewCount); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		detail.AverageRating = math.Round(average*100) / 100
+
+		var err error
+		if detail.LowestPrice30Days, err = db.lowestRecentPrice(detail.ID, detail.Price); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if detail.Images, err = db.loadProductImages(detail.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if detail.Options, err = db.loadProductOptions(detail.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if detail.Variants, err = db.loadProductVariants(detail.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.DB.Query("SELECT "+productColumns+" FROM products WHERE category_id = ? AND id <> ? AND quantity > 0 AND archived_at IS NULL ORDER BY id DESC LIMIT ?",
+			detail.CategoryID, detail.ID, relatedProductLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		detail.Related = []models.Product{}
+		for rows.Next() {
+			var product models.Product
+			if err := scanProduct(rows, &product); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			detail.Related = append(detail.Related, product)
+		}
+
+		body, err := json.Marshal(detail)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// ETag cevabın içeriğinden üretilir; ürün, yorumlar veya benzer ürünler değişince değişir
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(body, '\n'))
 	})
 }
 
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// productSorts are the sort_by values accepted by GetProducts; searchSorts adds
+// relevance, which is ordered in memory and has no column.
+var (
+	productSorts = map[string]string{"id": "id", "name": "name", "price": "price", "quantity": "quantity"}
+	searchSorts  = map[string]string{"id": "id", "name": "name", "price": "price", "quantity": "quantity", "relevance": ""}
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// productSortValue returns the value of the sort column of product for a page cursor.
+func productSortValue(product models.Product, sortBy string) interface{} {
+	switch sortBy {
+	case "name":
+		return product.Name
+	case "price":
+		return product.Price
+	case "quantity":
+		return product.Quantity
+	}
+	return product.ID
+}
+
 // GetProducts godoc
 // @Summary Get all products
-// @Description Get all products with optional filters
+// @Description Get a page of products with optional filters. Pages are keyset paginated: pass next_cursor
+// @Description from the response as cursor together with the same filters and sort to get the next page.
+// @Description search is a full-text query with prefix and typo tolerance; its results are sorted by relevance
+// @Description unless sort_by is given.
 // @Tags products
 // @Produce  json
-// @Param category query string false "Category"
+// @Param category query string false "Category ID or slug; includes its subcategories"
 // @Param search query string false "Search term"
-// @Param sort_by query string false "Sort by"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param seller_id query int false "Seller ID"
+// @Param min_rating query number false "Minimum average rating"
+// @Param in_stock query bool false "Only products with stock"
+// @Param sort_by query string false "Sort by: id, name, price, quantity or relevance (default id, relevance when searching)"
 // @Param order query string false "Order (asc or desc)"
-// @Success 200 {array} models.Product
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.Product}
+// @Failure 400 {string} string "Invalid filter"
 // @Failure 500 {string} string "Internal server error"
 // @Router /products [get]
 func (db *AppHandler) GetProducts() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		category := query.Get("category")
-		search := query.Get("search")
-		sortBy := query.Get("sort_by")
-		order := query.Get("order")
-
-		baseQuery := "SELECT id, name, description, quantity, price, seller_id, category, image_url FROM products WHERE 1=1" // 1=1 ek koşulların koyulabilmesi için
-		args := []interface{}{} //sorgu parametrelerini tutan slice
-
-		if category != "" {
-			baseQuery += " AND category = ?"
-			args = append(args, category)
-		}
-		if search != "" {
-			baseQuery += " AND (name LIKE ? OR description LIKE ?)"
-			search = "%" + search + "%"
-			args = append(args, search, search)
-		}
-		if sortBy != "" {
-			baseQuery += " ORDER BY " + sortBy
-			if order != "" {
-				baseQuery += " " + order
-			} else {
-				baseQuery += " ASC"
-			}
+		sorts, defaultSort, defaultDesc := productSorts, "id", false
+		if r.URL.Query().Get("search") != "" {
+			sorts, defaultSort = searchSorts, "relevance"
+			defaultDesc = r.URL.Query().Get("sort_by") == "" || r.URL.Query().Get("sort_by") == "relevance"
+		}
+		page, err := newPageRequest(r, sorts, defaultSort, defaultDesc)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		where, args, scores, err := db.productFilters(r.URL.Query())
+		if err == errInvalidFilter {
+			http.Error(w, "Invalid filter", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		rows, err := db.DB.Query(baseQuery, args...)
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM products"+where, args...).Scan(&total); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Alaka sıralaması SQL'de yapılamadığı için eşleşen tüm ürünler okunup bellekte sayfalanır
+		query, queryArgs := "SELECT "+productColumns+" FROM products"+where, args
+		if page.sortBy != "relevance" {
+			query, queryArgs = page.keyset(query, queryArgs, "id")
+		}
+		rows, err := db.DB.Query(query, queryArgs...)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var products []models.Product
+		products := []models.Product{}
 		for rows.Next() {
 			var product models.Product
-			if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Quantity, &product.Price, &product.SellerID, &product.Category, &product.ImageURL); err != nil {
+			if err := scanProduct(rows, &product); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			products = append(products, product)
 		}
+		if page.sortBy == "relevance" {
+			products = relevancePage(page, products, scores)
+		}
+
+		next := ""
+		if len(products) > page.limit {
+			products = products[:page.limit]
+			last := products[page.limit-1]
+			value := productSortValue(last, page.sortBy)
+			if page.sortBy == "relevance" {
+				value = scores[last.ID]
+			}
+			next = page.cursor(value, last.ID)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(products)
+		json.NewEncoder(w).Encode(models.Page{Items: products, NextCursor: next, Total: total, Limit: page.limit})
 	})
 }
+
+// productFilters builds the WHERE clause of the product listing from the query
+// parameters. When search is given, matches come from the search index and their
+// relevance scores are returned. Malformed parameters return errInvalidFilter.
+func (db *AppHandler) productFilters(query url.Values) (string, []interface{}, map[int]float64, error) {
+	where := " WHERE archived_at IS NULL" // arşivlenmiş ürünler listelenmez
+	args := []interface{}{}               //sorgu parametrelerini tutan slice
+
+	if category := query.Get("category"); category != "" {
+		condition, ids, err := db.categoryFilter(category)
+		if err == errUnknownCategory {
+			return "", nil, nil, errInvalidFilter
+		}
+		if err != nil {
+			return "", nil, nil, err
+		}
+		where += condition
+		args = append(args, ids...)
+	}
+	var scores map[int]float64
+	if term := query.Get("search"); term != "" {
+		if db.Search == nil {
+			where += " AND (name LIKE ? OR description LIKE ?)"
+			term = "%" + term + "%"
+			args = append(args, term, term)
+		} else {
+			var err error
+			if scores, err = db.searchProducts(term); err != nil {
+				return "", nil, nil, err
+			}
+			if len(scores) == 0 {
+				where += " AND 1=0"
+			} else {
+				placeholders, ids := idList(scores)
+				where += " AND id IN (" + placeholders + ")"
+				args = append(args, ids...)
+			}
+		}
+	}
+	if value := query.Get("min_price"); value != "" {
+		minPrice, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, nil, errInvalidFilter
+		}
+		where += " AND price >= ?"
+		args = append(args, minPrice)
+	}
+	if value := query.Get("max_price"); value != "" {
+		maxPrice, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, nil, errInvalidFilter
+		}
+		where += " AND price <= ?"
+		args = append(args, maxPrice)
+	}
+	if value := query.Get("seller_id"); value != "" {
+		sellerID, err := strconv.Atoi(value)
+		if err != nil {
+			return "", nil, nil, errInvalidFilter
+		}
+		where += " AND seller_id = ?"
+		args = append(args, sellerID)
+	}
+	if value := query.Get("min_rating"); value != "" {
+		minRating, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, nil, errInvalidFilter
+		}
+		where += " AND (SELECT AVG(rating) FROM reviews WHERE reviews.product_id = products.id) >= ?"
+		args = append(args, minRating)
+	}
+	if value := query.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", nil, nil, errInvalidFilter
+		}
+		if inStock {
+			where += " AND quantity > 0"
+		}
+	}
+	return where, args, scores, nil
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// productPurgeInterval is how often archived products are checked for purging.
+const productPurgeInterval = time.Hour
+
+// GetArchivedProducts godoc
+// @Summary Get archived products
+// @Description Get a page of archived products by admin
+// @Tags admin
+// @Produce  json
+// @Param seller_id query int false "Seller ID"
+// @Param sort_by query string false "Sort by: id, name, price or quantity (default id)"
+// @Param order query string false "Order (asc or desc)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.Product}
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/products/archived [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetArchivedProducts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, err := newPageRequest(r, productSorts, "id", false)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		where := " WHERE archived_at IS NOT NULL"
+		args := []interface{}{}
+		if value := r.URL.Query().Get("seller_id"); value != "" {
+			sellerID, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid filter", http.StatusBadRequest)
+				return
+			}
+			where += " AND seller_id = ?"
+			args = append(args, sellerID)
+		}
+
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM products"+where, args...).Scan(&total); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		query, queryArgs := page.keyset("SELECT "+productColumns+" FROM products"+where, args, "id")
+		rows, err := db.DB.Query(query, queryArgs...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		products := []models.Product{}
+		for rows.Next() {
+			var product models.Product
+			if err := scanProduct(rows, &product); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			products = append(products, product)
+		}
+
+		next := ""
+		if len(products) > page.limit {
+			products = products[:page.limit]
+			last := products[page.limit-1]
+			next = page.cursor(productSortValue(last, page.sortBy), last.ID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Page{Items: products, NextCursor: next, Total: total, Limit: page.limit})
+	})
+}
+
+// RestoreProduct godoc
+// @Summary Restore an archived product
+// @Description Restore an archived product by admin; it is listed and can be bought again
+// @Tags admin
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Success 200 {object} models.Product
+// @Failure 404 {string} string "Archived product not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/products/{id}/restore [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) RestoreProduct() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		productID := mux.Vars(r)["id"]
+
+		res, err := db.DB.Exec("UPDATE products SET archived_at = NULL WHERE id = ? AND archived_at IS NOT NULL", productID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			http.Error(w, "Archived product not found", http.StatusNotFound)
+			return
+		}
+
+		var product models.Product
+		if err := scanProduct(db.DB.QueryRow("SELECT "+productColumns+" FROM products WHERE id = ?", productID), &product); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		db.indexProduct(product)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(product)
+	})
+}
+
+// StartProductPurge purges, now and then every hour, the products that were
+// archived before the retention period and never ordered.
+func (db *AppHandler) StartProductPurge(retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(productPurgeInterval)
+		defer ticker.Stop()
+		for {
+			purged, err := db.PurgeArchivedProducts(time.Now().Add(-retention))
+			if err != nil {
+				log.Println("Error purging archived products: ", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d archived products", purged)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeArchivedProducts deletes the products archived before the given time
+// that no order refers to, together with their images, options, variants and
+// reviews. Products that were ordered stay archived for the order history.
+func (db *AppHandler) PurgeArchivedProducts(before time.Time) (int, error) {
+	rows, err := db.DB.Query(`SELECT id FROM products p WHERE archived_at < ?
+		AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.product_id = p.id)`, before)
+	if err != nil {
+		return 0, err
+	}
+	var productIDs []int
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, productID := range productIDs {
+		ok, err := db.purgeProduct(productID, before)
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeProduct deletes one archived product. It reports false when the product
+// was restored or ordered in the meantime.
+func (db *AppHandler) purgeProduct(productID int, before time.Time) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// Ürün kilitlenip koşullar tekrar kontrol edilir; bu arada geri yüklenmiş olabilir
+	var id int
+	err = tx.QueryRow(`SELECT id FROM products p WHERE id = ? AND archived_at < ?
+		AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.product_id = p.id) FOR UPDATE`, productID, before).Scan(&id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	rows, err := tx.Query("SELECT storage_key, thumbnail_key FROM product_images WHERE product_id = ?", productID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key, thumbKey string
+		if err := rows.Scan(&key, &thumbKey); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return false, err
+		}
+		keys = append(keys, key, thumbKey)
+	}
+	rows.Close()
+
+	for _, query := range []string{
+		"DELETE FROM cart_items WHERE product_id = ?",
+		"DELETE FROM stock_reservation_items WHERE product_id = ?",
+		"DELETE FROM product_variant_options WHERE variant_id IN (SELECT id FROM product_variants WHERE product_id = ?)",
+		"DELETE FROM product_variants WHERE product_id = ?",
+		"DELETE FROM product_option_values WHERE option_id IN (SELECT id FROM product_options WHERE product_id = ?)",
+		"DELETE FROM product_options WHERE product_id = ?",
+		"DELETE FROM product_images WHERE product_id = ?",
+		"DELETE FROM reviews WHERE product_id = ?",
+		"DELETE FROM product_price_schedules WHERE product_id = ?",
+		"DELETE FROM product_price_history WHERE product_id = ?",
+		"UPDATE product_import_rows SET product_id = NULL WHERE product_id = ?",
+		"DELETE FROM products WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, productID); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	db.deleteStoredImages(keys...)
+	return true, nil
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"e-ticaret-api/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// priceBoundaries split prices into the ranges counted by GetProductFacets.
+var priceBoundaries = []float64{100, 250, 500, 1000, 2500, 5000}
+
+// ratingThresholds are the "N stars & up" buckets counted by GetProductFacets.
+var ratingThresholds = []int{4, 3, 2, 1}
+
+// withoutFilters returns a copy of query without the given parameters. A facet is
+// counted without its own filter so the other values of that facet stay selectable.
+func withoutFilters(query url.Values, names ...string) url.Values {
+	copied := url.Values{}
+	for key, values := range query {
+		copied[key] = values
+	}
+	for _, name := range names {
+		copied.Del(name)
+	}
+	return copied
+}
+
+// GetProductFacets godoc
+// @Summary Get product facet counts
+// @Description Count products per category, seller, price range and rating bucket for the same filters as
+// @Description GET /products. Each facet ignores its own filter (category, seller_id, min_price/max_price,
+// @Description min_rating) so that other values of it can still be offered; total applies every filter.
+// @Description Category counts include the products of subcategories.
+// @Tags products
+// @Produce  json
+// @Param category query string false "Category ID or slug; includes its subcategories"
+// @Param search query string false "Search term"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param seller_id query int false "Seller ID"
+// @Param min_rating query number false "Minimum average rating"
+// @Param in_stock query bool false "Only products with stock"
+// @Success 200 {object} models.ProductFacets
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 500 {string} string "Internal server error"
+// @Router /products/facets [get]
+func (db *AppHandler) GetProductFacets() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		facets := models.ProductFacets{
+			Categories:  []models.CategoryFacet{},
+			Sellers:     []models.SellerFacet{},
+			PriceRanges: []models.PriceRangeFacet{},
+			Ratings:     []models.RatingFacet{},
+		}
+
+		steps := []struct {
+			ignore []string
+			count  func(where string, args []interface{}) error
+		}{
+			{nil, func(where string, args []interface{}) error {
+				return db.DB.QueryRow("SELECT COUNT(*) FROM products"+where, args...).Scan(&facets.Total)
+			}},
+			{[]string{"category"}, func(where string, args []interface{}) error {
+				return db.countCategories(where, args, &facets)
+			}},
+			{[]string{"seller_id"}, func(where string, args []interface{}) error {
+				return db.countSellers(where, args, &facets)
+			}},
+			{[]string{"min_price", "max_price"}, func(where string, args []interface{}) error {
+				return db.countPriceRanges(where, args, &facets)
+			}},
+			{[]string{"min_rating"}, func(where string, args []interface{}) error {
+				return db.countRatings(where, args, &facets)
+			}},
+		}
+
+		for _, step := range steps {
+			where, args, _, err := db.productFilters(withoutFilters(query, step.ignore...))
+			if err == errInvalidFilter {
+				http.Error(w, "Invalid filter", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := step.count(where, args); err != nil {
+				http.Error(w, "Error counting facets", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(facets)
+	})
+}
+
+func (db *AppHandler) countCategories(where string, args []interface{}, facets *models.ProductFacets) error {
+	rows, err := db.DB.Query("SELECT category_id, COUNT(*) FROM products"+where+" AND category_id IS NOT NULL GROUP BY category_id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	direct := make(map[int]int)
+	for rows.Next() {
+		var categoryID, count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return err
+		}
+		direct[categoryID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	categories, err := db.loadCategories()
+	if err != nil {
+		return err
+	}
+
+	// Üst kategoriler alt kategorilerindeki ürünleri de sayar
+	for _, category := range categories {
+		count := 0
+		for _, id := range descendantIDs(categories, category.ID) {
+			count += direct[id]
+		}
+		if count == 0 {
+			continue
+		}
+		facets.Categories = append(facets.Categories, models.CategoryFacet{
+			CategoryID: category.ID,
+			ParentID:   category.ParentID,
+			Name:       category.Name,
+			Slug:       category.Slug,
+			Count:      count,
+		})
+	}
+	sort.SliceStable(facets.Categories, func(i, j int) bool {
+		return facets.Categories[i].Count > facets.Categories[j].Count
+	})
+	return nil
+}
+
+func (db *AppHandler) countSellers(where string, args []interface{}, facets *models.ProductFacets) error {
+	// Filtre kolonları nitelendirilmemiş olduğu için join alt sorgunun dışında yapılır
+	rows, err := db.DB.Query(`SELECT f.seller_id, COALESCE(u.name, ''), COUNT(*) FROM (SELECT seller_id FROM products`+where+`) f
+		LEFT JOIN users u ON u.id = f.seller_id GROUP BY f.seller_id, u.name ORDER BY COUNT(*) DESC, f.seller_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet models.SellerFacet
+		if err := rows.Scan(&facet.SellerID, &facet.Name, &facet.Count); err != nil {
+			return err
+		}
+		facets.Sellers = append(facets.Sellers, facet)
+	}
+	return rows.Err()
+}
+
+func (db *AppHandler) countPriceRanges(where string, args []interface{}, facets *models.ProductFacets) error {
+	columns := make([]string, 0, len(priceBoundaries)+1)
+	lower := 0.0
+	for _, upper := range priceBoundaries {
+		columns = append(columns, fmt.Sprintf("COALESCE(SUM(price >= %g AND price < %g), 0)", lower, upper))
+		lower = upper
+	}
+	columns = append(columns, fmt.Sprintf("COALESCE(SUM(price >= %g), 0)", lower))
+
+	counts := make([]int, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := db.DB.QueryRow("SELECT "+strings.Join(columns, ", ")+" FROM products"+where, args...).Scan(dest...); err != nil {
+		return err
+	}
+
+	lower = 0
+	for i, count := range counts {
+		facet := models.PriceRangeFacet{Min: lower, Count: count}
+		if i < len(priceBoundaries) {
+			upper := priceBoundaries[i]
+			facet.Max = &upper
+			lower = upper
+		}
+		facets.PriceRanges = append(facets.PriceRanges, facet)
+	}
+	return nil
+}
+
+func (db *AppHandler) countRatings(where string, args []interface{}, facets *models.ProductFacets) error {
+	columns := make([]string, len(ratingThresholds))
+	for i, threshold := range ratingThresholds {
+		columns[i] = fmt.Sprintf("COALESCE(SUM(avg_rating >= %d), 0)", threshold)
+	}
+
+	counts := make([]int, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	query := "SELECT " + strings.Join(columns, ", ") +
+		" FROM (SELECT (SELECT AVG(rating) FROM reviews WHERE reviews.product_id = products.id) AS avg_rating FROM products" + where + ") t"
+	if err := db.DB.QueryRow(query, args...).Scan(dest...); err != nil {
+		return err
+	}
+
+	for i, threshold := range ratingThresholds {
+		facets.Ratings = append(facets.Ratings, models.RatingFacet{MinRating: threshold, Count: counts[i]})
+	}
+	return nil
+}
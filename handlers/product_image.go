@@ -0,0 +1,568 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"e-ticaret-api/models"
+	"e-ticaret-api/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxImageSize        = 5 << 20
+	maxImagePixels      = 40000000
+	maxImagesPerProduct = 20
+	thumbnailSize       = 320
+)
+
+// imageExtensions are the accepted image types, detected from the file content.
+var imageExtensions = map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif"}
+
+var (
+	errImageTooLarge = errors.New("image too large")
+	errImageType     = errors.New("unsupported image type")
+	errImageInvalid  = errors.New("invalid image")
+)
+
+// imageUpload is a validated upload with its rendered thumbnail.
+type imageUpload struct {
+	data          []byte
+	contentType   string
+	width, height int
+	thumbnail     []byte
+}
+
+// readImageUpload validates an uploaded file and renders its thumbnail. The
+// content type is sniffed from the data, not taken from the request, and the
+// pixel count is checked before decoding so huge images are not expanded in memory.
+func readImageUpload(header *multipart.FileHeader) (imageUpload, error) {
+	var upload imageUpload
+	if header.Size > maxImageSize {
+		return upload, errImageTooLarge
+	}
+	f, err := header.Open()
+	if err != nil {
+		return upload, err
+	}
+	defer f.Close()
+
+	upload.data, err = io.ReadAll(io.LimitReader(f, maxImageSize+1))
+	if err != nil {
+		return upload, err
+	}
+	if len(upload.data) > maxImageSize {
+		return upload, errImageTooLarge
+	}
+
+	upload.contentType = http.DetectContentType(upload.data)
+	if _, ok := imageExtensions[upload.contentType]; !ok {
+		return upload, errImageType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(upload.data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return upload, errImageInvalid
+	}
+	img, _, err := image.Decode(bytes.NewReader(upload.data))
+	if err != nil {
+		return upload, errImageInvalid
+	}
+	upload.width, upload.height = config.Width, config.Height
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return upload, err
+	}
+	upload.thumbnail = thumb.Bytes()
+	return upload, nil
+}
+
+// thumbnail scales img down so that its longer side is at most size pixels. Each
+// thumbnail pixel is the average of the source pixels it covers, composited on
+// white because thumbnails are JPEG and have no transparency.
+func thumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w >= h && w > size {
+		tw, th = size, max(1, h*size/w)
+	} else if h > w && h > size {
+		tw, th = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// Renkler alfa ile çarpılmış olduğu için beyaz zemin (1 - alfa) eklenerek elde edilir
+			white := 0xffff - a/n
+			dst.SetRGBA(x, y, color.RGBA{uint8((r/n + white) >> 8), uint8((g/n + white) >> 8), uint8((b/n + white) >> 8), 0xff})
+		}
+	}
+	return dst
+}
+
+// imageColumns are the product_images columns read by scanImage.
+const imageColumns = "id, product_id, content_type, width, height, position, is_primary, created_at"
+
+// scanImage scans a row selected with imageColumns into img and fills its URLs.
+func scanImage(row rowScanner, img *models.ProductImage) error {
+	if err := row.Scan(&img.ID, &img.ProductID, &img.ContentType, &img.Width, &img.Height, &img.Position, &img.Primary, &img.CreatedAt); err != nil {
+		return err
+	}
+	img.URL = fmt.Sprintf("/product-images/%d", img.ID)
+	img.ThumbnailURL = img.URL + "/thumbnail"
+	return nil
+}
+
+// loadProductImages returns the images of a product in display order.
+func (db *AppHandler) loadProductImages(productID int) ([]models.ProductImage, error) {
+	rows, err := db.DB.Query("SELECT "+imageColumns+" FROM product_images WHERE product_id = ? ORDER BY position, id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.ProductImage{}
+	for rows.Next() {
+		var img models.ProductImage
+		if err := scanImage(rows, &img); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// syncPrimaryImage makes sure a product with images has exactly one primary
+// image, the first one when none is marked, and copies its URL to image_url.
+func syncPrimaryImage(tx *sql.Tx, productID int) error {
+	var primaryID int
+	err := tx.QueryRow("SELECT id FROM product_images WHERE product_id = ? ORDER BY is_primary DESC, position, id LIMIT 1", productID).Scan(&primaryID)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec("UPDATE products SET image_url = '' WHERE id = ? AND image_url LIKE '/product-images/%'", productID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE product_images SET is_primary = (id = ?) WHERE product_id = ?", primaryID, productID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE products SET image_url = ? WHERE id = ?", fmt.Sprintf("/product-images/%d", primaryID), productID)
+	return err
+}
+
+// deleteStoredImages removes files from image storage. Failures only leave
+// unreferenced files behind, so they are logged instead of failing the request.
+func (db *AppHandler) deleteStoredImages(keys ...string) {
+	for _, key := range keys {
+		if err := db.Images.Delete(key); err != nil && err != storage.ErrNotFound {
+			log.Println("Error deleting stored image: ", err)
+		}
+	}
+}
+
+// UploadProductImages godoc
+// @Summary Upload product images
+// @Description Upload one or more JPEG, PNG or GIF images (multipart field "images", at most 5 MB each) for
+// @Description one of the seller's products. Images are appended in the order sent; the first image of a
+// @Description product becomes its primary image. A thumbnail is generated for every image.
+// @Tags products
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param images formData file true "Images"
+// @Success 201 {array} models.ProductImage
+// @Failure 400 {string} string "Invalid image"
+// @Failure 404 {string} string "Product not found"
+// @Failure 413 {string} string "Image too large"
+// @Failure 415 {string} string "Unsupported image type"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/images [post]
+func (db *AppHandler) UploadProductImages() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImagesPerProduct*maxImageSize+1<<20)
+		if err := r.ParseMultipartForm(8 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		headers := r.MultipartForm.File["images"]
+		if len(headers) == 0 {
+			http.Error(w, "No images uploaded", http.StatusBadRequest)
+			return
+		}
+
+		var count, position int
+		err := db.DB.QueryRow("SELECT COUNT(*), COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = ?", product.ID).Scan(&count, &position)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count+len(headers) > maxImagesPerProduct {
+			http.Error(w, fmt.Sprintf("A product can have at most %d images", maxImagesPerProduct), http.StatusBadRequest)
+			return
+		}
+
+		// Hiçbir dosya kaydedilmeden önce tüm yüklemeler doğrulanır
+		uploads := make([]imageUpload, len(headers))
+		for i, header := range headers {
+			upload, err := readImageUpload(header)
+			switch err {
+			case nil:
+				uploads[i] = upload
+			case errImageTooLarge:
+				http.Error(w, header.Filename+": image too large", http.StatusRequestEntityTooLarge)
+				return
+			case errImageType:
+				http.Error(w, header.Filename+": unsupported image type", http.StatusUnsupportedMediaType)
+				return
+			case errImageInvalid:
+				http.Error(w, header.Filename+": invalid image", http.StatusBadRequest)
+				return
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var stored []string
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		fail := func(msg string) {
+			tx.Rollback()
+			db.deleteStoredImages(stored...)
+			http.Error(w, msg, http.StatusInternalServerError)
+		}
+
+		for i, upload := range uploads {
+			name, err := randomToken(16)
+			if err != nil {
+				fail("Error naming image")
+				return
+			}
+			key := fmt.Sprintf("products/%d/%s%s", product.ID, name, imageExtensions[upload.contentType])
+			thumbKey := fmt.Sprintf("products/%d/%s_thumb.jpg", product.ID, name)
+
+			if err := db.Images.Put(key, bytes.NewReader(upload.data), upload.contentType); err != nil {
+				log.Println("Error storing image: ", err)
+				fail("Error storing image")
+				return
+			}
+			stored = append(stored, key)
+			if err := db.Images.Put(thumbKey, bytes.NewReader(upload.thumbnail), "image/jpeg"); err != nil {
+				log.Println("Error storing thumbnail: ", err)
+				fail("Error storing image")
+				return
+			}
+			stored = append(stored, thumbKey)
+
+			_, err = tx.Exec(`INSERT INTO product_images (product_id, storage_key, thumbnail_key, content_type, width, height, position, is_primary, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				product.ID, key, thumbKey, upload.contentType, upload.width, upload.height, position+i, false, time.Now())
+			if err != nil {
+				fail("Error saving image")
+				return
+			}
+		}
+		if err := syncPrimaryImage(tx, product.ID); err != nil {
+			fail("Error saving image")
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			db.deleteStoredImages(stored...)
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		images, err := db.loadProductImages(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(images)
+	})
+}
+
+// ReorderProductImages godoc
+// @Summary Reorder product images
+// @Description Set the display order of the images of one of the seller's products. image_ids must list every
+// @Description image of the product exactly once.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param body body object true "{\"image_ids\": [3, 1, 2]}"
+// @Success 200 {array} models.ProductImage
+// @Failure 400 {string} string "Invalid image order"
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/images/order [put]
+func (db *AppHandler) ReorderProductImages() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ImageIDs []int `json:"image_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		images, err := db.loadProductImages(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		existing := make(map[int]bool, len(images))
+		for _, img := range images {
+			existing[img.ID] = true
+		}
+		if len(req.ImageIDs) != len(images) {
+			http.Error(w, "Invalid image order", http.StatusBadRequest)
+			return
+		}
+		for _, id := range req.ImageIDs {
+			if !existing[id] {
+				http.Error(w, "Invalid image order", http.StatusBadRequest)
+				return
+			}
+			delete(existing, id)
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		for position, id := range req.ImageIDs {
+			if _, err := tx.Exec("UPDATE product_images SET position = ? WHERE id = ? AND product_id = ?", position, id, product.ID); err != nil {
+				tx.Rollback()
+				http.Error(w, "Error reordering images", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		if images, err = db.loadProductImages(product.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(images)
+	})
+}
+
+// SetPrimaryProductImage godoc
+// @Summary Set the primary product image
+// @Description Make an image the primary image of one of the seller's products; its URL becomes the product's image_url
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param image_id path int true "Image ID"
+// @Success 200 {array} models.ProductImage
+// @Failure 404 {string} string "Image not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/images/{image_id}/primary [put]
+func (db *AppHandler) SetPrimaryProductImage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		product, ok := db.findOwnedProduct(r, vars["id"])
+		if !ok {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM product_images WHERE id = ? AND product_id = ?", vars["image_id"], product.ID).Scan(&exists); err != nil || exists == 0 {
+			tx.Rollback()
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if _, err := tx.Exec("UPDATE product_images SET is_primary = (id = ?) WHERE product_id = ?", vars["image_id"], product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating image", http.StatusInternalServerError)
+			return
+		}
+		if err := syncPrimaryImage(tx, product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating image", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		images, err := db.loadProductImages(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(images)
+	})
+}
+
+// DeleteProductImage godoc
+// @Summary Delete a product image
+// @Description Delete an image of one of the seller's products. When the primary image is deleted the next
+// @Description image becomes primary.
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param image_id path int true "Image ID"
+// @Success 200 {string} string "Image deleted"
+// @Failure 404 {string} string "Image not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/images/{image_id} [delete]
+func (db *AppHandler) DeleteProductImage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		product, ok := db.findOwnedProduct(r, vars["id"])
+		if !ok {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var key, thumbKey string
+		err = tx.QueryRow("SELECT storage_key, thumbnail_key FROM product_images WHERE id = ? AND product_id = ? FOR UPDATE", vars["image_id"], product.ID).Scan(&key, &thumbKey)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if _, err := tx.Exec("DELETE FROM product_images WHERE id = ?", vars["image_id"]); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting image", http.StatusInternalServerError)
+			return
+		}
+		if err := syncPrimaryImage(tx, product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting image", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+		db.deleteStoredImages(key, thumbKey)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Image deleted"})
+	})
+}
+
+// GetProductImage godoc
+// @Summary Get a product image
+// @Description Serve an uploaded product image, or its JPEG thumbnail
+// @Tags products
+// @Produce  image/jpeg,image/png,image/gif
+// @Param image_id path int true "Image ID"
+// @Success 200 {file} file "Image"
+// @Failure 404 {string} string "Image not found"
+// @Router /product-images/{image_id} [get]
+// @Router /product-images/{image_id}/thumbnail [get]
+func (db *AppHandler) GetProductImage(thumb bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		imageID, err := strconv.Atoi(mux.Vars(r)["image_id"])
+		if err != nil {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+
+		var key, thumbKey, contentType string
+		err = db.DB.QueryRow("SELECT storage_key, thumbnail_key, content_type FROM product_images WHERE id = ?", imageID).Scan(&key, &thumbKey, &contentType)
+		if err != nil {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if thumb {
+			key, contentType = thumbKey, "image/jpeg"
+		}
+
+		f, err := db.Images.Get(key)
+		if err == storage.ErrNotFound {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("Error reading stored image: ", err)
+			http.Error(w, "Error reading image", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		// Görsel ID'si değişmeyen bir dosyaya karşılık geldiği için uzun süre önbelleğe alınabilir
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		io.Copy(w, f)
+	})
+}
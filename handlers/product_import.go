@@ -0,0 +1,658 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxImportSize = 20 << 20
+	maxImportRows = 50000
+	// importProgressEvery is how often, in rows, the counters of a running import are saved.
+	importProgressEvery = 100
+)
+
+// catalogColumns are the columns of catalog CSV files, in export order. The
+// JSON Lines format uses the same names as object keys.
+var catalogColumns = []string{"sku", "name", "description", "price", "quantity", "category", "image_url"}
+
+// importSlots limits how many imports run at the same time; others wait as pending.
+var importSlots = make(chan struct{}, 2)
+
+var errImportFormat = errors.New("format must be csv or jsonl")
+
+// catalogRow is one product of an import or export file. category is a
+// category slug or ID.
+type catalogRow struct {
+	SKU         string   `json:"sku"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       *float64 `json:"price"`
+	Quantity    *int     `json:"quantity"`
+	Category    string   `json:"category"`
+	ImageURL    string   `json:"image_url"`
+}
+
+// importFormat picks the file format from the format query parameter, the file
+// extension or the content type, in that order.
+func importFormat(format, filename, contentType string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(path.Ext(filename)) {
+		case ".csv":
+			format = "csv"
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		}
+	}
+	if format == "" {
+		switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+		case "text/csv":
+			format = "csv"
+		case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+			format = "jsonl"
+		}
+	}
+	if format != "csv" && format != "jsonl" {
+		return "", errImportFormat
+	}
+	return format, nil
+}
+
+// readCatalog calls each for every row of a catalog file with its line number.
+// Rows that cannot be parsed are passed with an error; only an unusable file
+// (such as a CSV header without the required columns) stops reading.
+func readCatalog(format string, data io.Reader, each func(line int, row catalogRow, err error) error) error {
+	if format == "jsonl" {
+		scanner := bufio.NewScanner(data)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			var row catalogRow
+			decoder := json.NewDecoder(bytes.NewReader(text))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(&row)
+			if err != nil {
+				err = fmt.Errorf("invalid JSON: %v", err)
+			}
+			if err := each(line, row, err); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %v", err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		known := false
+		for _, column := range catalogColumns {
+			known = known || column == name
+		}
+		if !known {
+			return fmt.Errorf("unknown CSV column %q", name)
+		}
+		index[name] = i
+	}
+	for _, required := range []string{"sku", "name", "price", "quantity", "category"} {
+		if _, ok := index[required]; !ok {
+			return fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		// Bozuk bir satır sadece o satırın hatası sayılır, okuma sonraki satırdan devam eder
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			if err := each(parseErr.StartLine, catalogRow{}, fmt.Errorf("invalid CSV: %v", parseErr.Err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := catalogRow{SKU: field("sku"), Name: field("name"), Description: field("description"), Category: field("category"), ImageURL: field("image_url")}
+		var rowErr error
+		if value := field("price"); value != "" {
+			price, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+			// ParseFloat "NaN" ve "Inf" değerlerini de kabul eder; NaN < 0 kontrolüne takılmaz
+			if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+				rowErr = fmt.Errorf("invalid price %q", value)
+			}
+			row.Price = &price
+		}
+		if value := field("quantity"); value != "" && rowErr == nil {
+			quantity, err := strconv.Atoi(value)
+			if err != nil {
+				rowErr = fmt.Errorf("invalid quantity %q", value)
+			}
+			row.Quantity = &quantity
+		}
+		if err := each(line, row, rowErr); err != nil {
+			return err
+		}
+	}
+}
+
+// validateCatalogRow checks a row and resolves its category.
+func validateCatalogRow(row *catalogRow, categories []models.Category) (models.Category, error) {
+	row.SKU, row.Name = strings.TrimSpace(row.SKU), strings.TrimSpace(row.Name)
+	switch {
+	case row.SKU == "":
+		return models.Category{}, errors.New("sku is required")
+	case len(row.SKU) > 64:
+		return models.Category{}, errors.New("sku is longer than 64 characters")
+	case row.Name == "":
+		return models.Category{}, errors.New("name is required")
+	case row.Price == nil || *row.Price < 0:
+		return models.Category{}, errors.New("price is required and cannot be negative")
+	case row.Quantity == nil || *row.Quantity < 0:
+		return models.Category{}, errors.New("quantity is required and cannot be negative")
+	}
+	category, ok := findCategory(categories, strings.TrimSpace(row.Category))
+	if !ok {
+		return models.Category{}, fmt.Errorf("unknown category %q", row.Category)
+	}
+	return category, nil
+}
+
+// errArchivedProduct is reported for rows whose SKU belongs to an archived product.
+var errArchivedProduct = errors.New("the product with this sku is archived")
+
+// upsertCatalogRow creates the seller's product with the row's SKU or updates
+// it. Stock and price of products sold in variants are kept from the variants;
+// stock reserved at checkout is subtracted from the row's quantity.
+func (db *AppHandler) upsertCatalogRow(sellerID int, row catalogRow, category models.Category) (productID int, created bool, message string, err error) {
+	product := models.Product{
+		Name:        row.Name,
+		Description: row.Description,
+		Quantity:    *row.Quantity,
+		Price:       *row.Price,
+		SellerID:    sellerID,
+		SKU:         row.SKU,
+		CategoryID:  category.ID,
+		Category:    category.Name,
+		ImageURL:    row.ImageURL,
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, false, "", err
+	}
+	var archived bool
+	err = tx.QueryRow("SELECT id, archived_at IS NOT NULL FROM products WHERE seller_id = ? AND sku = ? FOR UPDATE", sellerID, row.SKU).Scan(&product.ID, &archived)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec("INSERT INTO products (name, description, quantity, price, seller_id, sku, category_id, image_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			product.Name, product.Description, product.Quantity, product.Price, sellerID, product.SKU, product.CategoryID, product.ImageURL)
+		if err != nil {
+			tx.Rollback()
+			return 0, false, "", err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return 0, false, "", err
+		}
+		product.ID = int(id)
+		created = true
+	case err != nil:
+		tx.Rollback()
+		return 0, false, "", err
+	case archived:
+		tx.Rollback()
+		return 0, false, "", errArchivedProduct
+	default:
+		var variants bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM product_variants WHERE product_id = ?)", product.ID).Scan(&variants); err != nil {
+			tx.Rollback()
+			return 0, false, "", err
+		}
+		query := "UPDATE products SET name = ?, description = ?, quantity = ?, price = ?, category_id = ?, image_url = ? WHERE id = ?"
+		if !variants {
+			product.Quantity, err = availableStock(tx, product.ID, nil, product.Quantity)
+			if err != nil {
+				tx.Rollback()
+				return 0, false, "", err
+			}
+		}
+		args := []interface{}{product.Name, product.Description, product.Quantity, product.Price, product.CategoryID, product.ImageURL, product.ID}
+		if variants {
+			query = "UPDATE products SET name = ?, description = ?, category_id = ?, image_url = ? WHERE id = ?"
+			args = []interface{}{product.Name, product.Description, product.CategoryID, product.ImageURL, product.ID}
+			message = "price and quantity are managed on the variants and were not changed"
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return 0, false, "", err
+		}
+	}
+
+	source := "import"
+	if created {
+		source = "initial"
+	}
+	if err := recordProductPrice(tx, product.ID, source, time.Now()); err != nil {
+		tx.Rollback()
+		return 0, false, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, "", err
+	}
+
+	db.indexProduct(product)
+	return product.ID, created, message, nil
+}
+
+// runProductImport processes an import in the background and records the
+// outcome of every row.
+func (db *AppHandler) runProductImport(importID, sellerID int, format string, data []byte) {
+	importSlots <- struct{}{}
+	defer func() { <-importSlots }()
+
+	if _, err := db.DB.Exec("UPDATE product_imports SET status = 'processing' WHERE id = ?", importID); err != nil {
+		log.Println("Error starting product import: ", err)
+	}
+
+	fail := func(err error) {
+		log.Println("Product import failed: ", err)
+		if _, err := db.DB.Exec("UPDATE product_imports SET status = 'failed', error = ?, finished_at = ? WHERE id = ?", err.Error(), time.Now(), importID); err != nil {
+			log.Println("Error saving product import status: ", err)
+		}
+	}
+
+	categories, err := db.loadCategories()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var total, created, updated, failed int
+	seen := make(map[string]int)
+	saveProgress := func() error {
+		_, err := db.DB.Exec("UPDATE product_imports SET total_rows = ?, created = ?, updated = ?, failed = ? WHERE id = ?", total, created, updated, failed, importID)
+		return err
+	}
+
+	err = readCatalog(format, bytes.NewReader(data), func(line int, row catalogRow, rowErr error) error {
+		total++
+		if total > maxImportRows {
+			return fmt.Errorf("the file has more than %d rows", maxImportRows)
+		}
+
+		status, productID, message := "failed", 0, ""
+		if rowErr == nil {
+			var category models.Category
+			if category, rowErr = validateCatalogRow(&row, categories); rowErr == nil {
+				if first, ok := seen[row.SKU]; ok {
+					rowErr = fmt.Errorf("sku already used on line %d", first)
+				}
+			}
+			if rowErr == nil {
+				seen[row.SKU] = line
+				var isNew bool
+				productID, isNew, message, rowErr = db.upsertCatalogRow(sellerID, row, category)
+				switch {
+				case rowErr == errArchivedProduct || rowErr == errStockBelowReserved:
+				case rowErr != nil:
+					log.Println("Error importing product row: ", rowErr)
+					rowErr = errors.New("could not be saved")
+				case isNew:
+					status = "created"
+				default:
+					status = "updated"
+				}
+			}
+		}
+		if rowErr != nil {
+			message = rowErr.Error()
+		}
+
+		switch status {
+		case "created":
+			created++
+		case "updated":
+			updated++
+		default:
+			failed++
+		}
+		_, err := db.DB.Exec("INSERT INTO product_import_rows (import_id, line, sku, status, product_id, message) VALUES (?, ?, ?, ?, ?, ?)",
+			importID, line, row.SKU, status, sql.NullInt64{Int64: int64(productID), Valid: productID != 0}, message)
+		if err != nil {
+			return err
+		}
+		if total%importProgressEvery == 0 {
+			return saveProgress()
+		}
+		return nil
+	})
+	if err != nil {
+		saveProgress()
+		fail(err)
+		return
+	}
+
+	_, err = db.DB.Exec("UPDATE product_imports SET status = 'completed', total_rows = ?, created = ?, updated = ?, failed = ?, finished_at = ? WHERE id = ?",
+		total, created, updated, failed, time.Now(), importID)
+	if err != nil {
+		log.Println("Error saving product import status: ", err)
+	}
+}
+
+// FailInterruptedImports marks imports that were pending or running when the
+// server stopped as failed; their files were only kept in memory. It is called once at startup.
+func (db *AppHandler) FailInterruptedImports() error {
+	_, err := db.DB.Exec("UPDATE product_imports SET status = 'failed', error = ?, finished_at = ? WHERE status IN ('pending', 'processing')",
+		"interrupted by a server restart, upload the file again", time.Now())
+	return err
+}
+
+// findImport loads an import of the calling seller.
+func (db *AppHandler) findImport(r *http.Request) (models.ProductImport, bool) {
+	sellerID := r.Context().Value("userID").(int)
+
+	var job models.ProductImport
+	var errMsg sql.NullString
+	var finishedAt sql.NullTime
+	row := db.DB.QueryRow("SELECT id, seller_id, format, status, total_rows, created, updated, failed, error, created_at, finished_at FROM product_imports WHERE id = ? AND seller_id = ?",
+		mux.Vars(r)["id"], sellerID)
+	if err := row.Scan(&job.ID, &job.SellerID, &job.Format, &job.Status, &job.TotalRows, &job.Created, &job.Updated, &job.Failed, &errMsg, &job.CreatedAt, &finishedAt); err != nil {
+		return job, false
+	}
+	job.Error = errMsg.String
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.ResultURL = fmt.Sprintf("/product/imports/%d/result", job.ID)
+	return job, true
+}
+
+// ImportProducts godoc
+// @Summary Start a bulk product import
+// @Description Upload a CSV or JSON Lines file (multipart field "file" or the raw request body, at most 20 MB
+// @Description and 50000 rows) with the columns sku, name, description, price, quantity, category (slug or ID)
+// @Description and image_url. Rows are validated one by one and upserted by the seller's SKU in the
+// @Description background; poll the returned import and download its result for per-row errors. A seller can
+// @Description have one import pending or running at a time.
+// @Tags products
+// @Accept  multipart/form-data,text/csv,application/x-ndjson
+// @Produce  json
+// @Param format query string false "csv or jsonl (default from the file name or Content-Type)"
+// @Param file formData file false "Catalog file"
+// @Success 202 {object} models.ProductImport
+// @Failure 400 {string} string "Invalid file"
+// @Failure 409 {string} string "An import is already running"
+// @Failure 413 {string} string "File too large"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/imports [post]
+func (db *AppHandler) ImportProducts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sellerID := r.Context().Value("userID").(int)
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20)
+		var data []byte
+		filename, contentType := "", r.Header.Get("Content-Type")
+		var err error
+		if strings.HasPrefix(contentType, "multipart/form-data") {
+			file, header, ferr := r.FormFile("file")
+			if ferr != nil {
+				http.Error(w, "The file field is required", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			filename, contentType = header.Filename, header.Header.Get("Content-Type")
+			data, err = io.ReadAll(io.LimitReader(file, maxImportSize+1))
+		} else {
+			data, err = io.ReadAll(r.Body)
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || len(data) > maxImportSize {
+			http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error reading file", http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			http.Error(w, "The file is empty", http.StatusBadRequest)
+			return
+		}
+
+		format, err := importFormat(r.URL.Query().Get("format"), filename, contentType)
+		if err != nil {
+			http.Error(w, "Format must be csv or jsonl", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+		// Dosyalar bellekte tutulduğu için satıcı başına tek bir içe aktarma bekleyebilir;
+		// satıcı satırı kilitlenir ki aynı anda gelen yüklemeler kontrolü birlikte geçemesin
+		var lockedID int
+		var running bool
+		err = tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", sellerID).Scan(&lockedID)
+		if err == nil {
+			err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM product_imports WHERE seller_id = ? AND status IN ('pending', 'processing'))", sellerID).Scan(&running)
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error creating import", http.StatusInternalServerError)
+			return
+		}
+		if running {
+			tx.Rollback()
+			http.Error(w, "An import is already running; wait for it to finish", http.StatusConflict)
+			return
+		}
+
+		job := models.ProductImport{SellerID: sellerID, Format: format, Status: "pending", CreatedAt: time.Now()}
+		res, err := tx.Exec("INSERT INTO product_imports (seller_id, format, status, created_at) VALUES (?, ?, ?, ?)", job.SellerID, job.Format, job.Status, job.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error creating import", http.StatusInternalServerError)
+			return
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+		job.ID = int(id)
+		job.ResultURL = fmt.Sprintf("/product/imports/%d/result", job.ID)
+
+		go db.runProductImport(job.ID, sellerID, format, data)
+
+		w.Header().Set("Location", fmt.Sprintf("/product/imports/%d", job.ID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+}
+
+// GetProductImport godoc
+// @Summary Get a bulk product import
+// @Description Get the status and row counts of one of the seller's imports
+// @Tags products
+// @Produce  json
+// @Param id path int true "Import ID"
+// @Success 200 {object} models.ProductImport
+// @Failure 404 {string} string "Import not found"
+// @Router /product/imports/{id} [get]
+func (db *AppHandler) GetProductImport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, ok := db.findImport(r)
+		if !ok {
+			http.Error(w, "Import not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	})
+}
+
+// GetProductImportResult godoc
+// @Summary Download the result of a bulk product import
+// @Description Download a CSV file with the line, sku, status (created, updated or failed), product_id and
+// @Description message of every row processed so far
+// @Tags products
+// @Produce  text/csv
+// @Param id path int true "Import ID"
+// @Success 200 {file} file "Result CSV"
+// @Failure 404 {string} string "Import not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/imports/{id}/result [get]
+func (db *AppHandler) GetProductImportResult() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, ok := db.findImport(r)
+		if !ok {
+			http.Error(w, "Import not found", http.StatusNotFound)
+			return
+		}
+
+		rows, err := db.DB.Query("SELECT line, sku, status, COALESCE(product_id, 0), message FROM product_import_rows WHERE import_id = ? ORDER BY line", job.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-result.csv"`, job.ID))
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"line", "sku", "status", "product_id", "message"})
+		for rows.Next() {
+			var line, productID int
+			var sku, status, message string
+			if err := rows.Scan(&line, &sku, &status, &productID, &message); err != nil {
+				log.Println("Error reading import result: ", err)
+				break
+			}
+			id := ""
+			if productID != 0 {
+				id = strconv.Itoa(productID)
+			}
+			writer.Write([]string{strconv.Itoa(line), sku, status, id, message})
+		}
+		writer.Flush()
+	})
+}
+
+// ExportProducts godoc
+// @Summary Export the seller's products
+// @Description Stream all products of the seller as CSV or JSON Lines with the columns used by the import, so
+// @Description an edited export can be imported again. Products without a SKU are exported with an empty sku.
+// @Description The quantity includes the stock reserved at checkout.
+// @Tags products
+// @Produce  text/csv,application/x-ndjson
+// @Param format query string false "csv (default) or jsonl"
+// @Success 200 {file} file "Catalog file"
+// @Failure 400 {string} string "Invalid format"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/export [get]
+func (db *AppHandler) ExportProducts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sellerID := r.Context().Value("userID").(int)
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "jsonl" {
+			http.Error(w, "Format must be csv or jsonl", http.StatusBadRequest)
+			return
+		}
+
+		// Stok, içe aktarmada ödeme için ayrılan miktar düşüleceği için ayrılan miktarla birlikte yazılır
+		rows, err := db.DB.Query(`SELECT COALESCE(sku, ''), name, description, price,
+			quantity + COALESCE((SELECT SUM(ri.quantity) FROM stock_reservation_items ri
+				JOIN stock_reservations r ON r.id = ri.reservation_id WHERE r.status = 'active' AND ri.product_id = products.id), 0),
+			COALESCE((SELECT c.slug FROM categories c WHERE c.id = products.category_id), ''), image_url
+			FROM products WHERE seller_id = ? AND archived_at IS NULL ORDER BY id`, sellerID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="products.`+format+`"`)
+
+		// Katalog satır satır yazılır ve aralıklarla gönderilir; tamamı bellekte tutulmaz
+		flusher, _ := w.(http.Flusher)
+		writer := csv.NewWriter(w)
+		encoder := json.NewEncoder(w)
+		if format == "csv" {
+			writer.Write(catalogColumns)
+		}
+		for count := 1; rows.Next(); count++ {
+			var row catalogRow
+			var price float64
+			var quantity int
+			if err := rows.Scan(&row.SKU, &row.Name, &row.Description, &price, &quantity, &row.Category, &row.ImageURL); err != nil {
+				log.Println("Error exporting products: ", err)
+				break
+			}
+			row.Price, row.Quantity = &price, &quantity
+
+			if format == "csv" {
+				writer.Write([]string{row.SKU, row.Name, row.Description, strconv.FormatFloat(price, 'f', -1, 64), strconv.Itoa(quantity), row.Category, row.ImageURL})
+			} else {
+				encoder.Encode(row)
+			}
+			if count%importProgressEvery == 0 && flusher != nil {
+				writer.Flush()
+				flusher.Flush()
+			}
+		}
+		writer.Flush()
+	})
+}
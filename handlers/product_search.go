@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"e-ticaret-api/models"
+	"e-ticaret-api/search"
+	"log"
+	"sort"
+	"strings"
+)
+
+// maxSearchResults caps how many matches of a search are filtered and paginated.
+const maxSearchResults = 1000
+
+func productDocument(product models.Product) search.Document {
+	return search.Document{ID: product.ID, Name: product.Name, Description: product.Description, Category: product.Category}
+}
+
+// indexProduct adds or updates a product in the search index. The database is
+// the source of truth, so a failure is only logged.
+func (db *AppHandler) indexProduct(product models.Product) {
+	if db.Search == nil {
+		return
+	}
+	if err := db.Search.Index(productDocument(product)); err != nil {
+		log.Println("Error indexing product: ", err)
+	}
+}
+
+// unindexProduct removes a product from the search index.
+func (db *AppHandler) unindexProduct(productID int) {
+	if db.Search == nil {
+		return
+	}
+	if err := db.Search.Delete(productID); err != nil {
+		log.Println("Error removing product from search index: ", err)
+	}
+}
+
+// BuildSearchIndex indexes every product that is not archived. It is called once at startup.
+func (db *AppHandler) BuildSearchIndex() error {
+	rows, err := db.DB.Query("SELECT " + productColumns + " FROM products WHERE archived_at IS NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return err
+		}
+		if err := db.Search.Index(productDocument(product)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// searchProducts returns the relevance scores of products matching term.
+func (db *AppHandler) searchProducts(term string) (map[int]float64, error) {
+	results, err := db.Search.Search(term, maxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[int]float64, len(results))
+	for _, result := range results {
+		scores[result.ID] = result.Score
+	}
+	return scores, nil
+}
+
+// idList returns "?, ?, ?" placeholders and arguments for the IDs in scores.
+func idList(scores map[int]float64) (string, []interface{}) {
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// relevancePage orders products by score and cuts the page after the cursor.
+// Like keyset, it keeps one row more than the limit to tell whether a next page exists.
+func relevancePage(page pageRequest, products []models.Product, scores map[int]float64) []models.Product {
+	// desc: en alakalı önce; eşit skorlarda ID sırası yönü takip eder
+	before := func(score float64, id int, otherScore float64, otherID int) bool {
+		if score != otherScore {
+			return (score > otherScore) == page.desc
+		}
+		return (id > otherID) == page.desc
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return before(scores[products[i].ID], products[i].ID, scores[products[j].ID], products[j].ID)
+	})
+
+	start := 0
+	if page.after != nil {
+		afterScore, _ := page.after.Value.(float64)
+		for start < len(products) && !before(afterScore, page.after.ID, scores[products[start].ID], products[start].ID) {
+			start++
+		}
+	}
+	products = products[start:]
+	if len(products) > page.limit+1 {
+		products = products[:page.limit+1]
+	}
+	return products
+}
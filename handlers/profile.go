@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"e-ticaret-api/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetMe godoc
+// @Summary Get own profile
+// @Description Get the authenticated user's profile
+// @Tags me
+// @Produce  json
+// @Success 200 {object} models.User
+// @Failure 404 {string} string "User not found"
+// @Router /me [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetMe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, name, role, verified, suspended, totp_enabled FROM users WHERE id = ?", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Verified, &user.Suspended, &user.TwoFactorEnabled); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	})
+}
+
+// UpdateMe godoc
+// @Summary Update own profile
+// @Description Update the authenticated user's name and email. Changing the email requires verifying the new address.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"name\": \"John Doe\", \"email\": \"new@example.com\"}"
+// @Success 200 {object} models.User
+// @Failure 400 {string} string "Invalid request"
+// @Failure 409 {string} string "Email already in use"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) UpdateMe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var req struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, name, role, verified, suspended, totp_enabled FROM users WHERE id = ?", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Verified, &user.Suspended, &user.TwoFactorEnabled); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if req.Name != "" {
+			user.Name = req.Name
+		}
+
+		emailChanged := false
+		if email := strings.TrimSpace(req.Email); email != "" && !strings.EqualFold(email, user.Email) {
+			if !strings.Contains(email, "@") {
+				http.Error(w, "Invalid email", http.StatusBadRequest)
+				return
+			}
+			var count int
+			if err := db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?", email, userID).Scan(&count); err != nil {
+				http.Error(w, "Error checking email", http.StatusInternalServerError)
+				return
+			}
+			if count > 0 {
+				http.Error(w, "Email already in use", http.StatusConflict)
+				return
+			}
+			user.Email = email
+			user.Verified = false
+			emailChanged = true
+		}
+
+		_, err := db.DB.Exec("UPDATE users SET name = ?, email = ?, verified = ? WHERE id = ?", user.Name, user.Email, user.Verified, userID)
+		if err != nil {
+			http.Error(w, "Error updating profile", http.StatusInternalServerError)
+			return
+		}
+
+		if emailChanged {
+			if err := db.sendVerificationEmail(userID, user.Email); err != nil {
+				log.Println("Error sending verification email: ", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the authenticated user's password. Other sessions are signed out.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"old_password\": \"...\", \"new_password\": \"...\"}"
+// @Success 200 {string} string "Password updated"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Old password is incorrect"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/password [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) ChangePassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+
+		var req struct {
+			OldPassword string `json:"old_password"`
+			NewPassword string `json:"new_password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if len(req.NewPassword) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+
+		var storedPassword string
+		if err := db.DB.QueryRow("SELECT password FROM users WHERE id = ?", claims.UserID).Scan(&storedPassword); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(req.OldPassword)); err != nil {
+			http.Error(w, "Old password is incorrect", http.StatusUnauthorized)
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "Error hashing password", http.StatusInternalServerError)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE users SET password = ? WHERE id = ?", string(hashedPassword), claims.UserID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating password", http.StatusInternalServerError)
+			return
+		}
+
+		// Mevcut oturum dışındaki oturumlar kapatılır
+		if err := revokeSessions(tx, claims.UserID, claims.SessionID, time.Now()); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password updated"})
+	})
+}
@@ -48,11 +48,16 @@ func (db *AppHandler) CreateReview() http.Handler {
 
 // GetReviews godoc
 // @Summary Get product reviews
-// @Description Get a list of reviews for a product
+// @Description Get a page of reviews for a product, newest first by default
 // @Tags Reviews
 // @Produce  json
 // @Param product_id path int true "Product ID"
-// @Success 200 {array} models.Review
+// @Param sort_by query string false "Sort by: id or rating (default id)"
+// @Param order query string false "Order (asc or desc, default desc)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} models.Page{items=[]models.Review}
+// @Failure 400 {string} string "Invalid pagination parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Router /reviews/{product_id} [get]
 func (db *AppHandler) GetReviews() http.Handler {
@@ -60,14 +65,27 @@ func (db *AppHandler) GetReviews() http.Handler {
 		vars := mux.Vars(r)
 		productID := vars["product_id"]
 
-		rows, err := db.DB.Query("SELECT id, product_id, user_id, rating, comment, created_at FROM reviews WHERE product_id = ?", productID)
+		page, err := newPageRequest(r, reviewSorts, "id", true)
+		if err != nil {
+			http.Error(w, "Invalid pagination parameters", http.StatusBadRequest)
+			return
+		}
+
+		var total int
+		if err := db.DB.QueryRow("SELECT COUNT(*) FROM reviews WHERE product_id = ?", productID).Scan(&total); err != nil {
+			http.Error(w, "Error fetching reviews", http.StatusInternalServerError)
+			return
+		}
+
+		query, args := page.keyset("SELECT id, product_id, user_id, rating, comment, created_at FROM reviews WHERE product_id = ?", []interface{}{productID}, "id")
+		rows, err := db.DB.Query(query, args...)
 		if err != nil {
 			http.Error(w, "Error fetching reviews", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var reviews []models.Review
+		reviews := []models.Review{}
 		for rows.Next() {
 			var review models.Review
 			if err := rows.Scan(&review.ID, &review.ProductID, &review.UserID, &review.Rating, &review.Comment, &review.CreatedAt); err != nil {
@@ -77,7 +95,21 @@ func (db *AppHandler) GetReviews() http.Handler {
 			reviews = append(reviews, review)
 		}
 
+		next := ""
+		if len(reviews) > page.limit {
+			reviews = reviews[:page.limit]
+			last := reviews[page.limit-1]
+			var value interface{} = last.ID
+			if page.sortBy == "rating" {
+				value = last.Rating
+			}
+			next = page.cursor(value, last.ID)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(reviews)
+		json.NewEncoder(w).Encode(models.Page{Items: reviews, NextCursor: next, Total: total, Limit: page.limit})
 	})
 }
+
+// reviewSorts are the sort_by values accepted by GetReviews.
+var reviewSorts = map[string]string{"id": "id", "rating": "rating"}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ApplyForSeller godoc
+// @Summary Apply to become a seller
+// @Description Submit a seller application for admin review
+// @Tags seller
+// @Accept  json
+// @Produce  json
+// @Param application body models.SellerApplication true "Seller application"
+// @Success 201 {object} models.SellerApplication
+// @Failure 400 {string} string "Invalid request"
+// @Failure 409 {string} string "Application already pending"
+// @Failure 500 {string} string "Internal server error"
+// @Router /seller/apply [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) ApplyForSeller() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+		userRole := r.Context().Value("role").(string)
+		if userRole != "customer" {
+			http.Error(w, "Only customers can apply to become sellers", http.StatusBadRequest)
+			return
+		}
+
+		var application models.SellerApplication
+		if err := json.NewDecoder(r.Body).Decode(&application); err != nil || application.CompanyName == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var pending int
+		err := db.DB.QueryRow("SELECT COUNT(*) FROM seller_applications WHERE user_id = ? AND status = 'pending'", userID).Scan(&pending)
+		if err != nil {
+			http.Error(w, "Error checking applications", http.StatusInternalServerError)
+			return
+		}
+		if pending > 0 {
+			http.Error(w, "Application already pending", http.StatusConflict)
+			return
+		}
+
+		application.UserID = userID
+		application.Status = "pending"
+		application.CreatedAt = time.Now()
+		application.ReviewedAt = nil
+		application.ReviewedBy = nil
+
+		res, err := db.DB.Exec("INSERT INTO seller_applications (user_id, company_name, tax_number, message, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			application.UserID, application.CompanyName, application.TaxNumber, application.Message, application.Status, application.CreatedAt)
+		if err != nil {
+			http.Error(w, "Error creating application", http.StatusInternalServerError)
+			return
+		}
+
+		lastInsertID, err := res.LastInsertId()
+		if err != nil {
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		application.ID = int(lastInsertID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(application)
+	})
+}
+
+// GetSellerApplications godoc
+// @Summary Get seller applications
+// @Description Get seller applications by admin, optionally filtered by status
+// @Tags admin
+// @Produce  json
+// @Param status query string false "Status (pending, approved, rejected)"
+// @Success 200 {array} models.SellerApplication
+// @Failure 403 {string} string "Only admin can access this endpoint"
+// @Failure 500 {string} string "Error fetching applications"
+// @Router /admin/seller-applications [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetSellerApplications() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userRole := r.Context().Value("role").(string)
+		if userRole != "admin" {
+			http.Error(w, "Only admin can access this endpoint", http.StatusForbidden)
+			return
+		}
+
+		query := "SELECT id, user_id, company_name, tax_number, message, status, created_at, reviewed_at, reviewed_by FROM seller_applications"
+		args := []interface{}{}
+		if status := r.URL.Query().Get("status"); status != "" {
+			query += " WHERE status = ?"
+			args = append(args, status)
+		}
+		query += " ORDER BY created_at"
+
+		rows, err := db.DB.Query(query, args...)
+		if err != nil {
+			http.Error(w, "Error fetching applications", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var applications []models.SellerApplication
+		for rows.Next() {
+			var application models.SellerApplication
+			var reviewedAt sql.NullTime
+			var reviewedBy sql.NullInt64
+			if err := rows.Scan(&application.ID, &application.UserID, &application.CompanyName, &application.TaxNumber, &application.Message, &application.Status, &application.CreatedAt, &reviewedAt, &reviewedBy); err != nil {
+				http.Error(w, "Error scanning application", http.StatusInternalServerError)
+				return
+			}
+			if reviewedAt.Valid {
+				application.ReviewedAt = &reviewedAt.Time
+			}
+			if reviewedBy.Valid {
+				id := int(reviewedBy.Int64)
+				application.ReviewedBy = &id
+			}
+			applications = append(applications, application)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(applications)
+	})
+}
+
+// ReviewSellerApplication godoc
+// @Summary Approve or reject a seller application
+// @Description Approve or reject a pending seller application; approval grants the seller role
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Application ID"
+// @Param status body string true "approved or rejected"
+// @Success 200 {string} string "Application reviewed"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Application not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/seller-applications/{id} [put]
+// @Security ApiKeyAuth
+func (db *AppHandler) ReviewSellerApplication() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.Context().Value("userID").(int)
+		userRole := r.Context().Value("role").(string)
+		if userRole != "admin" {
+			http.Error(w, "Only admin can review applications", http.StatusForbidden)
+			return
+		}
+
+		vars := mux.Vars(r)
+		applicationID := vars["id"]
+
+		var req struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Status != "approved" && req.Status != "rejected") {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var applicantID int
+		err = tx.QueryRow("SELECT user_id FROM seller_applications WHERE id = ? AND status = 'pending'", applicationID).Scan(&applicantID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Application not found", http.StatusNotFound)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE seller_applications SET status = ?, reviewed_at = ?, reviewed_by = ? WHERE id = ?", req.Status, time.Now(), adminID, applicationID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating application", http.StatusInternalServerError)
+			return
+		}
+
+		if req.Status == "approved" {
+			_, err = tx.Exec("UPDATE users SET role = 'seller' WHERE id = ? AND role = 'customer'", applicantID)
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error updating user role", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Application " + req.Status})
+	})
+}
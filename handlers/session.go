@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// describeDevice turns a user agent into a short label such as "Firefox on Linux".
+func describeDevice(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"curl/", "curl"}, {"PostmanRuntime/", "Postman"}, {"okhttp/", "Android app"},
+	}
+	systems := []struct{ token, name string }{
+		{"Windows", "Windows"}, {"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+
+	browser, system := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
+
+// revokeSessions ends the user's sessions except keepSessionID (empty ends all)
+// together with their refresh tokens.
+func revokeSessions(tx *sql.Tx, userID int, keepSessionID string, now time.Time) error {
+	_, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND family_id <> ? AND revoked_at IS NULL", now, userID, keepSessionID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL", now, userID, keepSessionID)
+	return err
+}
+
+// GetSessions godoc
+// @Summary List active sessions
+// @Description List the devices the authenticated user is signed in on
+// @Tags me
+// @Produce  json
+// @Success 200 {array} models.Session
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/sessions [get]
+// @Security ApiKeyAuth
+func (db *AppHandler) GetSessions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+
+		// Yenileme tokenının süresi dolmuş oturumlar artık kullanılamaz, listelenmez
+		rows, err := db.DB.Query(`SELECT s.id, s.device, s.ip, s.user_agent, s.created_at, s.last_seen_at FROM sessions s
+			WHERE s.user_id = ? AND s.revoked_at IS NULL
+			AND EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.family_id = s.id AND rt.revoked_at IS NULL AND rt.expires_at > ?)
+			ORDER BY s.last_seen_at DESC`, claims.UserID, time.Now())
+		if err != nil {
+			http.Error(w, "Error fetching sessions", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		sessions := []models.Session{}
+		for rows.Next() {
+			var session models.Session
+			if err := rows.Scan(&session.ID, &session.Device, &session.IP, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt); err != nil {
+				http.Error(w, "Error scanning session", http.StatusInternalServerError)
+				return
+			}
+			session.Current = session.ID == claims.SessionID
+			sessions = append(sessions, session)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	})
+}
+
+// RevokeSession godoc
+// @Summary Sign out a session
+// @Description Sign out one of the authenticated user's sessions. Its tokens stop working immediately.
+// @Tags me
+// @Produce  json
+// @Param   id  path  string  true  "Session ID"
+// @Success 200 {string} string "Session revoked"
+// @Failure 404 {string} string "Session not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/sessions/{id} [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) RevokeSession() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+		vars := mux.Vars(r)
+		sessionID := vars["id"]
+
+		var count int
+		err := db.DB.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, claims.UserID).Scan(&count)
+		if err != nil {
+			http.Error(w, "Error fetching session", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		if err := db.revokeFamily(sessionID); err != nil {
+			http.Error(w, "Error revoking session", http.StatusInternalServerError)
+			return
+		}
+		if sessionID == claims.SessionID {
+			clearTokenCookies(w)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked"})
+	})
+}
+
+// RevokeOtherSessions godoc
+// @Summary Sign out other sessions
+// @Description Sign out every session of the authenticated user except the current one
+// @Tags me
+// @Produce  json
+// @Success 200 {string} string "Other sessions revoked"
+// @Failure 500 {string} string "Internal server error"
+// @Router /me/sessions [delete]
+// @Security ApiKeyAuth
+func (db *AppHandler) RevokeOtherSessions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := revokeSessions(tx, claims.UserID, claims.SessionID, time.Now()); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Other sessions revoked"})
+	})
+}
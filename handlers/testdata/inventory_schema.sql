@@ -0,0 +1,132 @@
+-- Tables and columns used by the stock tests in inventory_test.go. The tests
+-- apply this file to the database in TEST_DATABASE_URL, keeping existing tables.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL,
+    role VARCHAR(32) NOT NULL DEFAULT 'customer',
+    verified BOOLEAN NOT NULL DEFAULT FALSE,
+    suspended BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at DATETIME NULL
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    parent_id INT NULL,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL UNIQUE,
+    position INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    quantity INT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    seller_id INT NOT NULL,
+    sku VARCHAR(64) NULL,
+    category_id INT NULL,
+    image_url VARCHAR(1024) NOT NULL DEFAULT '',
+    archived_at DATETIME NULL,
+    UNIQUE KEY products_seller_sku (seller_id, sku)
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    price DECIMAL(10, 2) NOT NULL,
+    quantity INT NOT NULL,
+    image_url VARCHAR(1024) NOT NULL DEFAULT '',
+    KEY product_variants_product (product_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_price_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    changed_at DATETIME NOT NULL,
+    KEY product_price_history_product (product_id)
+);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL,
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    district VARCHAR(255) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    postal_code VARCHAR(16) NOT NULL,
+    country VARCHAR(64) NOT NULL,
+    is_default_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default_billing BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL,
+    KEY addresses_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS carts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    cart_id INT NOT NULL,
+    product_id INT NOT NULL,
+    variant_id INT NULL,
+    quantity INT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    KEY cart_items_cart (cart_id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    total_price DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    created_at DATETIME NOT NULL,
+    shipping_address_id INT NULL,
+    shipping_address TEXT NULL,
+    billing_address TEXT NULL,
+    KEY orders_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    variant_id INT NULL,
+    sku VARCHAR(64) NOT NULL DEFAULT '',
+    quantity INT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    KEY order_items_order (order_id),
+    KEY order_items_product (product_id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    KEY stock_reservations_user (user_id, status),
+    KEY stock_reservations_expiry (status, expires_at)
+);
+
+CREATE TABLE IF NOT EXISTS stock_reservation_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    reservation_id INT NOT NULL,
+    product_id INT NOT NULL,
+    variant_id INT NULL,
+    quantity INT NOT NULL,
+    KEY stock_reservation_items_reservation (reservation_id),
+    KEY stock_reservation_items_product (product_id)
+);
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// randomToken returns n random bytes encoded as hex.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest of an opaque token; only this digest is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens signs a short-lived access token and persists a new refresh token
+// for the given user. An empty familyID starts a new refresh token family, which
+// is recorded as a session with the device and IP of r. Every call rotates the
+// session's CSRF token used by cookie authentication.
+func (db *AppHandler) issueTokens(r *http.Request, user models.User, familyID string) (models.TokenResponse, error) {
+	var resp models.TokenResponse
+
+	if familyID == "" {
+		id, err := randomToken(16)
+		if err != nil {
+			return resp, err
+		}
+		familyID = id
+	}
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return resp, err
+	}
+
+	now := time.Now()
+	expirationTime := now.Add(accessTokenTTL)
+	claims := &models.Claims{
+		Username:  user.Email,
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: familyID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
+	tokenString, err := db.Keys.Sign(claims)
+	if err != nil {
+		return resp, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return resp, err
+	}
+	_, err = db.DB.Exec("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		user.ID, familyID, hashToken(refreshToken), now.Add(refreshTokenTTL), now)
+	if err != nil {
+		return resp, err
+	}
+
+	csrfToken, err := randomToken(32)
+	if err != nil {
+		return resp, err
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	_, err = db.DB.Exec(`INSERT INTO sessions (id, user_id, device, ip, user_agent, csrf_hash, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE csrf_hash = VALUES(csrf_hash), last_seen_at = VALUES(last_seen_at)`,
+		familyID, user.ID, describeDevice(userAgent), clientIP(r), userAgent, hashToken(csrfToken), now, now)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Token = tokenString
+	resp.RefreshToken = refreshToken
+	resp.ExpiresAt = expirationTime.Unix()
+	resp.CSRFToken = csrfToken
+	return resp, nil
+}
+
+// setTokenCookie stores the tokens for browser clients. The access and refresh
+// tokens are HttpOnly; the CSRF token is readable by scripts so it can be sent
+// back in the X-CSRF-Token header.
+func setTokenCookie(w http.ResponseWriter, tokens models.TokenResponse) {
+	sessionExpiry := time.Now().Add(refreshTokenTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    tokens.Token,
+		Path:     "/",
+		Expires:  time.Unix(tokens.ExpiresAt, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    tokens.RefreshToken,
+		Path:     "/token/refresh",
+		Expires:  sessionExpiry,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    tokens.CSRFToken,
+		Path:     "/",
+		Expires:  sessionExpiry,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearTokenCookies removes the cookies set by setTokenCookie.
+func clearTokenCookies(w http.ResponseWriter) {
+	for name, path := range map[string]string{"token": "/", "refresh_token": "/token/refresh", "csrf_token": "/"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     path,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			HttpOnly: name != "csrf_token",
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// revokeFamily revokes every refresh token in a family that is still active and
+// ends the session, which also invalidates its access tokens.
+func (db *AppHandler) revokeFamily(familyID string) error {
+	now := time.Now()
+	if _, err := db.DB.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, familyID); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now, familyID)
+	return err
+}
+
+// RefreshToken godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a rotated refresh token. Browser clients
+// @Description may omit the body and send the refresh_token cookie together with the X-CSRF-Token header.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  false  "{\"refresh_token\": \"...\"}"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Invalid refresh token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /token/refresh [post]
+func (db *AppHandler) RefreshToken() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		fromCookie := false
+		if req.RefreshToken == "" {
+			if cookie, err := r.Cookie("refresh_token"); err == nil {
+				req.RefreshToken = cookie.Value
+				fromCookie = true
+			}
+		}
+		if req.RefreshToken == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			tokenID   int
+			userID    int
+			familyID  string
+			expiresAt time.Time
+			revokedAt sql.NullTime
+		)
+		row := db.DB.QueryRow("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?", hashToken(req.RefreshToken))
+		if err := row.Scan(&tokenID, &userID, &familyID, &expiresAt, &revokedAt); err != nil {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		// Daha önce kullanılmış bir token tekrar geldiyse çalınmış olabilir, tüm aileyi iptal et
+		if revokedAt.Valid {
+			db.revokeFamily(familyID)
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if time.Now().After(expiresAt) {
+			http.Error(w, "Refresh token expired", http.StatusUnauthorized)
+			return
+		}
+
+		// Çerezle gelen istekler tarayıcı tarafından otomatik gönderilebileceği için CSRF tokenı istenir
+		if fromCookie {
+			var csrfHash string
+			err := db.DB.QueryRow("SELECT csrf_hash FROM sessions WHERE id = ?", familyID).Scan(&csrfHash)
+			if err != nil || subtle.ConstantTimeCompare([]byte(csrfHash), []byte(hashToken(r.Header.Get("X-CSRF-Token")))) != 1 {
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
+		res, err := db.DB.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), tokenID)
+		if err != nil {
+			http.Error(w, "Error rotating refresh token", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			db.revokeFamily(familyID)
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		var user models.User
+		row = db.DB.QueryRow("SELECT id, email, name, role, suspended FROM users WHERE id = ?", userID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Suspended); err != nil {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if user.Suspended {
+			db.revokeFamily(familyID)
+			http.Error(w, "Account suspended", http.StatusForbidden)
+			return
+		}
+
+		tokens, err := db.issueTokens(r, user, familyID)
+		if err != nil {
+			http.Error(w, "Error issuing tokens", http.StatusInternalServerError)
+			return
+		}
+
+		if fromCookie {
+			setTokenCookie(w, tokens)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	})
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the current access token and its refresh token family
+// @Tags auth
+// @Produce  json
+// @Success 200 {string} string "Logged out"
+// @Failure 500 {string} string "Internal server error"
+// @Router /logout [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) Logout() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+
+		if err := db.revokeFamily(claims.SessionID); err != nil {
+			http.Error(w, "Error revoking session", http.StatusInternalServerError)
+			return
+		}
+
+		_, err := db.DB.Exec("INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)", claims.Id, time.Unix(claims.ExpiresAt, 0))
+		if err != nil {
+			http.Error(w, "Error revoking token", http.StatusInternalServerError)
+			return
+		}
+
+		clearTokenCookies(w)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+	})
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying tokens issued by this API, including keys kept for rotation
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} jwtkeys.JWKS
+// @Router /.well-known/jwks.json [get]
+func (db *AppHandler) JWKS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(db.Keys.JWKS())
+	})
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"e-ticaret-api/totp"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	totpIssuer         = "E-Ticaret"
+	challengeTTL       = 5 * time.Minute
+	recoveryCodeCount  = 10
+	totpAllowedSkew    = 1
+	purposeTwoFactor   = "2fa"
+	purposeTwoFAEnroll = "2fa_enroll"
+)
+
+// issueChallenge signs a short-lived token that only proves the password step of login.
+func (db *AppHandler) issueChallenge(user models.User, purpose string) (models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return challenge, err
+	}
+
+	now := time.Now()
+	expirationTime := now.Add(challengeTTL)
+	claims := &models.Claims{
+		Username: user.Email,
+		UserID:   user.ID,
+		Role:     user.Role,
+		Purpose:  purpose,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
+	tokenString, err := db.Keys.Sign(claims)
+	if err != nil {
+		return challenge, err
+	}
+
+	challenge.TwoFactorRequired = true
+	challenge.EnrollmentRequired = purpose == purposeTwoFAEnroll
+	challenge.ChallengeToken = tokenString
+	challenge.ExpiresAt = expirationTime.Unix()
+	return challenge, nil
+}
+
+// consumeChallenge marks a challenge token as used. It fails if the token was already used.
+func (db *AppHandler) consumeChallenge(claims *models.Claims) error {
+	_, err := db.DB.Exec("INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)", claims.Id, time.Unix(claims.ExpiresAt, 0))
+	return err
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a fresh set, returning the plain codes.
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hashToken(code)); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// checkSecondFactor validates a TOTP code, rejecting replays of already used steps,
+// or consumes a recovery code.
+func (db *AppHandler) checkSecondFactor(userID int, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		res, err := db.DB.Exec("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+			time.Now(), userID, hashToken(strings.ToLower(strings.TrimSpace(recoveryCode))))
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	var secret sql.NullString
+	var lastStep int64
+	if err := db.DB.QueryRow("SELECT totp_secret, totp_last_step FROM users WHERE id = ?", userID).Scan(&secret, &lastStep); err != nil {
+		return false, err
+	}
+	if !secret.Valid {
+		return false, nil
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now(), totpAllowedSkew)
+	if !ok || step <= lastStep {
+		return false, nil
+	}
+
+	res, err := db.DB.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete login with a second factor
+// @Description Exchange a login challenge token and a TOTP or recovery code for access and refresh tokens
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"challenge_token\": \"...\", \"code\": \"123456\"} or {\"challenge_token\": \"...\", \"recovery_code\": \"abcde-12345\"}"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Invalid code"
+// @Failure 500 {string} string "Internal server error"
+// @Router /login/2fa [post]
+func (db *AppHandler) LoginTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ChallengeToken string `json:"challenge_token"`
+			Code           string `json:"code"`
+			RecoveryCode   string `json:"recovery_code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		claims := &models.Claims{}
+		if err := db.Keys.Parse(req.ChallengeToken, claims); err != nil || claims.Purpose != purposeTwoFactor {
+			http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		ip := clientIP(r)
+		wait, err := db.loginRetryAfter(claims.Username, ip)
+		if err != nil {
+			http.Error(w, "Error checking login attempts", http.StatusInternalServerError)
+			return
+		}
+		if wait > 0 {
+			tooManyAttempts(w, wait)
+			return
+		}
+
+		ok, err := db.checkSecondFactor(claims.UserID, req.Code, req.RecoveryCode)
+		if err != nil {
+			http.Error(w, "Error verifying code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			db.recordLoginFailure(claims.Username, ip)
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+
+		// Challenge token yalnızca bir kez kullanılabilir
+		if err := db.consumeChallenge(claims); err != nil {
+			http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		var user models.User
+		row := db.DB.QueryRow("SELECT id, email, name, role, suspended FROM users WHERE id = ?", claims.UserID)
+		if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Suspended); err != nil {
+			http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+			return
+		}
+		if user.Suspended {
+			http.Error(w, "Account suspended", http.StatusForbidden)
+			return
+		}
+
+		tokens, err := db.issueTokens(r, user, "")
+		if err != nil {
+			http.Error(w, "Error issuing tokens", http.StatusInternalServerError)
+			return
+		}
+		// Şifre girişi sayacı sıfırlamaz; kod denemeleri giriş tamamlanana kadar sayılır
+		db.clearLoginFailures(claims.Username)
+
+		setTokenCookie(w, tokens)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	})
+}
+
+// EnrollTwoFactor godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret and provisioning URI (to be shown as a QR code) for an admin or seller
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} models.TwoFactorEnrollment
+// @Failure 403 {string} string "Two-factor authentication is only available for admins and sellers"
+// @Failure 409 {string} string "Two-factor authentication already enabled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /2fa/enroll [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) EnrollTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+		if claims.Role != "admin" && claims.Role != "seller" {
+			http.Error(w, "Two-factor authentication is only available for admins and sellers", http.StatusForbidden)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			http.Error(w, "Error generating secret", http.StatusInternalServerError)
+			return
+		}
+
+		res, err := db.DB.Exec("UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = ?", secret, claims.UserID, false)
+		if err != nil {
+			http.Error(w, "Error saving secret", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Two-factor authentication already enabled", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.TwoFactorEnrollment{
+			Secret:          secret,
+			ProvisioningURI: totp.ProvisioningURI(secret, totpIssuer, claims.Username),
+		})
+	})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm two-factor enrollment
+// @Description Activate two-factor authentication with a code from the authenticator app and receive recovery codes.
+// @Description When called with an enrollment challenge token, access and refresh tokens are returned as well.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"code\": \"123456\"}"
+// @Success 200 {object} object "recovery_codes and, for enrollment challenges, tokens"
+// @Failure 400 {string} string "Invalid code"
+// @Failure 500 {string} string "Internal server error"
+// @Router /2fa/confirm [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) ConfirmTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*models.Claims)
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var secret sql.NullString
+		var enabled bool
+		if err := db.DB.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = ?", claims.UserID).Scan(&secret, &enabled); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if enabled {
+			http.Error(w, "Two-factor authentication already enabled", http.StatusConflict)
+			return
+		}
+		if !secret.Valid {
+			http.Error(w, "Two-factor enrollment not started", http.StatusBadRequest)
+			return
+		}
+
+		step, ok := totp.Validate(secret.String, req.Code, time.Now(), totpAllowedSkew)
+		if !ok {
+			http.Error(w, "Invalid code", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE users SET totp_enabled = ?, totp_last_step = ? WHERE id = ?", true, step, claims.UserID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error enabling two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		codes, err := replaceRecoveryCodes(tx, claims.UserID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error generating recovery codes", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		response := struct {
+			RecoveryCodes []string              `json:"recovery_codes"`
+			Tokens        *models.TokenResponse `json:"tokens,omitempty"`
+		}{RecoveryCodes: codes}
+
+		// Zorunlu kurulum akışında girişi burada tamamla
+		if claims.Purpose == purposeTwoFAEnroll {
+			if err := db.consumeChallenge(claims); err != nil {
+				http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+				return
+			}
+			user := models.User{ID: claims.UserID, Email: claims.Username, Role: claims.Role}
+			tokens, err := db.issueTokens(r, user, "")
+			if err != nil {
+				http.Error(w, "Error issuing tokens", http.StatusInternalServerError)
+				return
+			}
+			db.clearLoginFailures(claims.Username)
+			setTokenCookie(w, tokens)
+			response.Tokens = &tokens
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication with a valid code. Not allowed for admins.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body  body  object  true  "{\"code\": \"123456\"}"
+// @Success 200 {string} string "Two-factor authentication disabled"
+// @Failure 400 {string} string "Invalid code"
+// @Failure 403 {string} string "Two-factor authentication is required for admins"
+// @Failure 500 {string} string "Internal server error"
+// @Router /2fa/disable [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) DisableTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+		userRole := r.Context().Value("role").(string)
+		if userRole == "admin" {
+			http.Error(w, "Two-factor authentication is required for admins", http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		ok, err := db.checkSecondFactor(userID, req.Code, req.RecoveryCode)
+		if err != nil {
+			http.Error(w, "Error verifying code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Invalid code", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE users SET totp_enabled = ?, totp_secret = NULL, totp_last_step = 0 WHERE id = ?", false, userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error disabling two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting recovery codes", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+	})
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"database/sql"
+	"e-ticaret-api/models"
+	"e-ticaret-api/search"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// variantColumns are the product_variants columns read by scanVariant.
+const variantColumns = "id, product_id, sku, price, quantity, image_url"
+
+// scanVariant scans a row selected with variantColumns into variant.
+func scanVariant(row rowScanner, variant *models.ProductVariant) error {
+	return row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &variant.Price, &variant.Quantity, &variant.ImageURL)
+}
+
+// loadProductOptions returns the option types of a product with their values in display order.
+func (db *AppHandler) loadProductOptions(productID int) ([]models.ProductOption, error) {
+	rows, err := db.DB.Query(`SELECT o.id, o.name, v.value FROM product_options o
+		JOIN product_option_values v ON v.option_id = o.id
+		WHERE o.product_id = ? ORDER BY o.position, o.id, v.position, v.id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []models.ProductOption
+	for rows.Next() {
+		var id int
+		var name, value string
+		if err := rows.Scan(&id, &name, &value); err != nil {
+			return nil, err
+		}
+		if len(options) == 0 || options[len(options)-1].ID != id {
+			options = append(options, models.ProductOption{ID: id, Name: name})
+		}
+		options[len(options)-1].Values = append(options[len(options)-1].Values, value)
+	}
+	return options, rows.Err()
+}
+
+// loadProductVariants returns the variants of a product with their option values.
+func (db *AppHandler) loadProductVariants(productID int) ([]models.ProductVariant, error) {
+	rows, err := db.DB.Query("SELECT "+variantColumns+" FROM product_variants WHERE product_id = ? ORDER BY id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.ProductVariant
+	index := make(map[int]int)
+	for rows.Next() {
+		variant := models.ProductVariant{Options: map[string]string{}}
+		if err := scanVariant(rows, &variant); err != nil {
+			return nil, err
+		}
+		index[variant.ID] = len(variants)
+		variants = append(variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	optionRows, err := db.DB.Query(`SELECT pvo.variant_id, o.name, ov.value FROM product_variant_options pvo
+		JOIN product_option_values ov ON ov.id = pvo.option_value_id
+		JOIN product_options o ON o.id = ov.option_id
+		WHERE o.product_id = ?`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var variantID int
+		var name, value string
+		if err := optionRows.Scan(&variantID, &name, &value); err != nil {
+			return nil, err
+		}
+		if i, ok := index[variantID]; ok {
+			variants[i].Options[name] = value
+		}
+	}
+	return variants, optionRows.Err()
+}
+
+// variantCombination resolves the option values chosen for a variant to their
+// IDs. Every option of the product must be given exactly once with one of its
+// values. The IDs are returned sorted so equal combinations compare equal.
+func (db *AppHandler) variantCombination(productID int, chosen map[string]string) ([]int, bool, error) {
+	rows, err := db.DB.Query(`SELECT o.name, v.id, v.value FROM product_options o
+		JOIN product_option_values v ON v.option_id = o.id WHERE o.product_id = ?`, productID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	// Seçenek ve değer adları büyük/küçük harf ve Türkçe karakter farkı gözetmeden eşleştirilir
+	values := make(map[string]map[string]int)
+	for rows.Next() {
+		var name, value string
+		var id int
+		if err := rows.Scan(&name, &id, &value); err != nil {
+			return nil, false, err
+		}
+		key := search.Normalize(name)
+		if values[key] == nil {
+			values[key] = make(map[string]int)
+		}
+		values[key][search.Normalize(value)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(values) == 0 || len(chosen) != len(values) {
+		return nil, false, nil
+	}
+	ids := make([]int, 0, len(chosen))
+	for name, value := range chosen {
+		id, ok := values[search.Normalize(name)][search.Normalize(strings.TrimSpace(value))]
+		if !ok {
+			return nil, false, nil
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, true, nil
+}
+
+// combinationTaken reports whether another variant of the product already has the option values ids.
+func (db *AppHandler) combinationTaken(productID int, ids []int) (bool, error) {
+	rows, err := db.DB.Query(`SELECT pvo.variant_id, pvo.option_value_id FROM product_variant_options pvo
+		JOIN product_variants pv ON pv.id = pvo.variant_id
+		WHERE pv.product_id = ? ORDER BY pvo.variant_id, pvo.option_value_id`, productID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	combinations := make(map[int][]int)
+	for rows.Next() {
+		var variantID, valueID int
+		if err := rows.Scan(&variantID, &valueID); err != nil {
+			return false, err
+		}
+		combinations[variantID] = append(combinations[variantID], valueID)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, existing := range combinations {
+		if equalInts(existing, ids) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasVariants reports whether the product is sold in variants.
+func (db *AppHandler) hasVariants(productID int) (bool, error) {
+	var count int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM product_variants WHERE product_id = ?", productID).Scan(&count)
+	return count > 0, err
+}
+
+var errVariantRequired = errors.New("variant required")
+
+// purchasePrice returns the unit price of a product, or of the chosen variant for
+// products sold in variants. errVariantRequired is returned when such a product is
+// bought without a variant and sql.ErrNoRows when the product or variant does not exist
+// or the product is archived.
+func (db *AppHandler) purchasePrice(productID int, variantID *int) (float64, error) {
+	var price float64
+	if variantID != nil {
+		err := db.DB.QueryRow(`SELECT v.price FROM product_variants v JOIN products p ON p.id = v.product_id
+			WHERE v.id = ? AND v.product_id = ? AND p.archived_at IS NULL`, *variantID, productID).Scan(&price)
+		return price, err
+	}
+
+	variants, err := db.hasVariants(productID)
+	if err != nil {
+		return 0, err
+	}
+	if variants {
+		return 0, errVariantRequired
+	}
+	err = db.DB.QueryRow("SELECT price FROM products WHERE id = ? AND archived_at IS NULL", productID).Scan(&price)
+	return price, err
+}
+
+// syncVariantTotals sets the stock of a product to the total stock of its
+// variants and its price to the lowest variant price, so listings, filters and
+// sorting keep working on products. A changed price is added to the price history.
+func syncVariantTotals(tx *sql.Tx, productID int) error {
+	_, err := tx.Exec(`UPDATE products SET
+		quantity = (SELECT COALESCE(SUM(quantity), 0) FROM product_variants WHERE product_id = ?),
+		price = COALESCE((SELECT MIN(price) FROM product_variants WHERE product_id = ?), price)
+		WHERE id = ?`, productID, productID, productID)
+	if err != nil {
+		return err
+	}
+	return recordProductPrice(tx, productID, "variant", time.Now())
+}
+
+// SetProductOptions godoc
+// @Summary Set the option types of a product
+// @Description Replace the option types (such as size and color) and their values of one of the seller's
+// @Description products. Options cannot be changed while the product has variants.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param options body []models.ProductOption true "Options with their values"
+// @Success 200 {array} models.ProductOption
+// @Failure 400 {string} string "Invalid options"
+// @Failure 404 {string} string "Product not found"
+// @Failure 409 {string} string "Product has variants"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/options [put]
+func (db *AppHandler) SetProductOptions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var options []models.ProductOption
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		names := make(map[string]bool)
+		for i := range options {
+			options[i].Name = strings.TrimSpace(options[i].Name)
+			key := search.Normalize(options[i].Name)
+			if key == "" || names[key] || len(options[i].Values) == 0 {
+				http.Error(w, "Invalid options", http.StatusBadRequest)
+				return
+			}
+			names[key] = true
+
+			values := make(map[string]bool)
+			for j, value := range options[i].Values {
+				value = strings.TrimSpace(value)
+				if value == "" || values[search.Normalize(value)] {
+					http.Error(w, "Invalid options", http.StatusBadRequest)
+					return
+				}
+				values[search.Normalize(value)] = true
+				options[i].Values[j] = value
+			}
+		}
+
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		variants, err := db.hasVariants(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if variants {
+			http.Error(w, "Delete the variants of the product before changing its options", http.StatusConflict)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec("DELETE FROM product_option_values WHERE option_id IN (SELECT id FROM product_options WHERE product_id = ?)", product.ID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting options", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec("DELETE FROM product_options WHERE product_id = ?", product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting options", http.StatusInternalServerError)
+			return
+		}
+
+		for i := range options {
+			res, err := tx.Exec("INSERT INTO product_options (product_id, name, position) VALUES (?, ?, ?)", product.ID, options[i].Name, i)
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error saving options", http.StatusInternalServerError)
+				return
+			}
+			optionID, err := res.LastInsertId()
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+				return
+			}
+			options[i].ID = int(optionID)
+
+			for j, value := range options[i].Values {
+				if _, err := tx.Exec("INSERT INTO product_option_values (option_id, value, position) VALUES (?, ?, ?)", optionID, value, j); err != nil {
+					tx.Rollback()
+					http.Error(w, "Error saving options", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		if options == nil {
+			options = []models.ProductOption{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(options)
+	})
+}
+
+// CreateVariant godoc
+// @Summary Add a variant to a product
+// @Description Add a SKU to one of the seller's products. options must give one value for every option type
+// @Description of the product, and each combination can only be used once. The product's stock becomes the
+// @Description total stock of its variants and its price the lowest variant price.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param variant body models.ProductVariant true "Variant"
+// @Success 201 {object} models.ProductVariant
+// @Failure 400 {string} string "Invalid variant"
+// @Failure 404 {string} string "Product not found"
+// @Failure 409 {string} string "SKU or combination already in use"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/variants [post]
+func (db *AppHandler) CreateVariant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var variant models.ProductVariant
+		if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		variant.SKU = strings.TrimSpace(variant.SKU)
+		if variant.SKU == "" || variant.Price < 0 || variant.Quantity < 0 {
+			http.Error(w, "Invalid variant", http.StatusBadRequest)
+			return
+		}
+
+		product, ok := db.findOwnedProduct(r, mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		variant.ProductID = product.ID
+
+		valueIDs, ok, err := db.variantCombination(product.ID, variant.Options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Options must give one value for every option of the product", http.StatusBadRequest)
+			return
+		}
+		taken, err := db.combinationTaken(product.ID, valueIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, "A variant with these options already exists", http.StatusConflict)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		res, err := tx.Exec("INSERT INTO product_variants (product_id, sku, price, quantity, image_url) VALUES (?, ?, ?, ?, ?)",
+			variant.ProductID, variant.SKU, variant.Price, variant.Quantity, variant.ImageURL)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "SKU already in use", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error adding variant", http.StatusInternalServerError)
+			return
+		}
+		variantID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error getting last insert ID", http.StatusInternalServerError)
+			return
+		}
+		variant.ID = int(variantID)
+
+		for _, valueID := range valueIDs {
+			if _, err := tx.Exec("INSERT INTO product_variant_options (variant_id, option_value_id) VALUES (?, ?)", variant.ID, valueID); err != nil {
+				tx.Rollback()
+				http.Error(w, "Error adding variant", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := syncVariantTotals(tx, product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating product stock", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(variant)
+	})
+}
+
+// variantPatch holds the fields of a variant update; fields left out stay nil.
+type variantPatch struct {
+	SKU      *string  `json:"sku"`
+	Price    *float64 `json:"price"`
+	Quantity *int     `json:"quantity"`
+	ImageURL *string  `json:"image_url"`
+}
+
+// UpdateVariant godoc
+// @Summary Update a variant
+// @Description Change the SKU, price, stock or image of a variant of one of the seller's products. Only the
+// @Description fields present in the body are changed; to change its options delete and re-create the variant.
+// @Description Stock reserved at checkout is subtracted from the given stock.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param variant_id path int true "Variant ID"
+// @Param variant body models.ProductVariant true "Fields to update"
+// @Success 200 {object} models.ProductVariant
+// @Failure 400 {string} string "Invalid variant"
+// @Failure 404 {string} string "Variant not found"
+// @Failure 409 {string} string "SKU already in use or stock below the quantity reserved at checkout"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/variants/{variant_id} [put]
+// @Router /product/{id}/variants/{variant_id} [patch]
+func (db *AppHandler) UpdateVariant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var patch variantPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if (patch.SKU != nil && strings.TrimSpace(*patch.SKU) == "") || (patch.Price != nil && *patch.Price < 0) || (patch.Quantity != nil && *patch.Quantity < 0) {
+			http.Error(w, "Invalid variant", http.StatusBadRequest)
+			return
+		}
+
+		product, ok := db.findOwnedProduct(r, vars["id"])
+		if !ok {
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		// Ürün satırı varyanttan önce kilitlenir; ödeme adımları stoğu aynı sırayla kilitler
+		if err := tx.QueryRow("SELECT id FROM products WHERE id = ? FOR UPDATE", product.ID).Scan(&product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+		var variant models.ProductVariant
+		row := tx.QueryRow("SELECT "+variantColumns+" FROM product_variants WHERE id = ? AND product_id = ? FOR UPDATE", vars["variant_id"], product.ID)
+		if err := scanVariant(row, &variant); err != nil {
+			tx.Rollback()
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+		if patch.SKU != nil {
+			variant.SKU = strings.TrimSpace(*patch.SKU)
+		}
+		if patch.Price != nil {
+			variant.Price = *patch.Price
+		}
+		if patch.Quantity != nil {
+			variant.Quantity, err = availableStock(tx, product.ID, &variant.ID, *patch.Quantity)
+			if err == errStockBelowReserved {
+				tx.Rollback()
+				http.Error(w, "Stock is below the quantity reserved at checkout", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				tx.Rollback()
+				http.Error(w, "Error updating variant", http.StatusInternalServerError)
+				return
+			}
+		}
+		if patch.ImageURL != nil {
+			variant.ImageURL = *patch.ImageURL
+		}
+
+		_, err = tx.Exec("UPDATE product_variants SET sku = ?, price = ?, quantity = ?, image_url = ? WHERE id = ?",
+			variant.SKU, variant.Price, variant.Quantity, variant.ImageURL, variant.ID)
+		if isDuplicateKey(err) {
+			tx.Rollback()
+			http.Error(w, "SKU already in use", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating variant", http.StatusInternalServerError)
+			return
+		}
+		if err := syncVariantTotals(tx, product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating product stock", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		variants, err := db.loadProductVariants(product.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, loaded := range variants {
+			if loaded.ID == variant.ID {
+				variant.Options = loaded.Options
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(variant)
+	})
+}
+
+// DeleteVariant godoc
+// @Summary Delete a variant
+// @Description Delete a variant of one of the seller's products and remove it from carts. Ordered items keep
+// @Description the variant's SKU.
+// @Tags products
+// @Produce  json
+// @Param id path int true "Product ID"
+// @Param variant_id path int true "Variant ID"
+// @Success 200 {string} string "Variant deleted"
+// @Failure 404 {string} string "Variant not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /product/{id}/variants/{variant_id} [delete]
+func (db *AppHandler) DeleteVariant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		variantID, err := strconv.Atoi(vars["variant_id"])
+		if err != nil {
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+
+		product, ok := db.findOwnedProduct(r, vars["id"])
+		if !ok {
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		res, err := tx.Exec("DELETE FROM product_variants WHERE id = ? AND product_id = ?", variantID, product.ID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error deleting variant", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			http.Error(w, "Variant not found", http.StatusNotFound)
+			return
+		}
+
+		for _, query := range []string{
+			"DELETE FROM product_variant_options WHERE variant_id = ?",
+			"DELETE FROM cart_items WHERE variant_id = ?",
+			"DELETE FROM stock_reservation_items WHERE variant_id = ?",
+		} {
+			if _, err := tx.Exec(query, variantID); err != nil {
+				tx.Rollback()
+				http.Error(w, "Error deleting variant", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := syncVariantTotals(tx, product.ID); err != nil {
+			tx.Rollback()
+			http.Error(w, "Error updating product stock", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Variant deleted"})
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// sendVerificationEmail creates a new verification token for the user,
+// invalidating older ones, and mails it to the given address.
+func (db *AppHandler) sendVerificationEmail(userID int, email string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = db.DB.Exec("UPDATE email_verifications SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec("INSERT INTO email_verifications (user_id, email, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, email, hashToken(token), now.Add(emailVerificationTTL), now)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("E-posta adresinizi doğrulamak için kodunuz: %s\n\nBu kod %d saat geçerlidir.", token, int(emailVerificationTTL.Hours()))
+	return db.Mailer.Send(email, "E-posta doğrulama", body)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Mark the account as verified using the token sent by email
+// @Tags auth
+// @Produce  json
+// @Param   token  query  string  true  "Verification token"
+// @Success 200 {string} string "Email verified"
+// @Failure 400 {string} string "Invalid or expired token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /verify-email [get]
+func (db *AppHandler) VerifyEmail() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		tx, err := db.DB.Begin()
+		if err != nil {
+			http.Error(w, "Transaction begin error", http.StatusInternalServerError)
+			return
+		}
+
+		var verificationID, userID int
+		var email string
+		err = tx.QueryRow("SELECT id, user_id, email FROM email_verifications WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), now).Scan(&verificationID, &userID, &email)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+
+		res, err := tx.Exec("UPDATE email_verifications SET used_at = ? WHERE id = ? AND used_at IS NULL", now, verificationID)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error consuming verification token", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+
+		// Token gönderildikten sonra adres değiştiyse doğrulama geçersizdir
+		res, err = tx.Exec("UPDATE users SET verified = ? WHERE id = ? AND email = ?", true, userID, email)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Error verifying user", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Transaction commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Email verified"})
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification token to the authenticated user's email address
+// @Tags auth
+// @Produce  json
+// @Success 200 {string} string "Verification email sent"
+// @Failure 400 {string} string "Email already verified"
+// @Failure 500 {string} string "Internal server error"
+// @Router /verify-email/resend [post]
+// @Security ApiKeyAuth
+func (db *AppHandler) ResendVerification() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var email string
+		var verified bool
+		if err := db.DB.QueryRow("SELECT email, verified FROM users WHERE id = ?", userID).Scan(&email, &verified); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if verified {
+			http.Error(w, "Email already verified", http.StatusBadRequest)
+			return
+		}
+
+		if err := db.sendVerificationEmail(userID, email); err != nil {
+			http.Error(w, "Error sending verification email", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+	})
+}
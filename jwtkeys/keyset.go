@@ -0,0 +1,204 @@
+// Package jwtkeys loads the asymmetric keys used to sign and verify JWTs and
+// publishes their public halves as a JSON Web Key Set.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const minRSABits = 2048
+
+// Key is a single key identified by its kid. Keys without a private half can
+// only verify tokens; they are kept around after rotation until old tokens expire.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key accepted for verification and the one used for signing.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// JWK is the public part of a key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadDir reads every *.pem file in dir. The file name without extension (and
+// without a ".pub" suffix) is the kid. Private keys (PKCS#8 RSA or Ed25519, or
+// PKCS#1 RSA) can sign; public keys (PKIX) only verify. The signing key is
+// activeKID, or the private key with the greatest kid when activeKID is empty,
+// so date-named files rotate naturally.
+func LoadDir(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{keys: make(map[string]*Key)}
+	var privateIDs []string
+	for _, path := range paths {
+		kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".pem"), ".pub")
+		if _, exists := set.keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", kid)
+		}
+
+		key, err := loadKey(path, kid)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		set.keys[kid] = key
+		if key.Private != nil {
+			privateIDs = append(privateIDs, kid)
+		}
+	}
+
+	if activeKID == "" {
+		if len(privateIDs) == 0 {
+			return nil, fmt.Errorf("no private keys found in %s", dir)
+		}
+		sort.Strings(privateIDs)
+		activeKID = privateIDs[len(privateIDs)-1]
+	}
+
+	signing, ok := set.keys[activeKID]
+	if !ok || signing.Private == nil {
+		return nil, fmt.Errorf("signing key %q not found", activeKID)
+	}
+	set.signing = signing
+	return set, nil
+}
+
+func loadKey(path, kid string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = SigningMethodEd25519, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = SigningMethodEd25519, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if pub, ok := key.Public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.Private)
+}
+
+// Parse verifies tokenString into claims. The token must name a known kid and
+// use exactly that key's algorithm, so "none", HS256 or algorithm confusion
+// attacks are rejected.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) error {
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg(), SigningMethodEd25519.Alg()}}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.Public, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// JWKS returns the public keys of the set, sorted by kid.
+func (s *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, kid := range ids {
+		key := s.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
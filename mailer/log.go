@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// LogMailer is a stand-in for local runs. It appends messages to the file at
+// Path, or writes them to the standard logger when Path is empty.
+type LogMailer struct {
+	Path string
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{Path: path}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	msg := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), to, subject, body)
+	if m.Path == "" {
+		log.Print(msg)
+		return nil
+	}
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(msg)
+	return err
+}
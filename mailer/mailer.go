@@ -0,0 +1,6 @@
+package mailer
+
+// Mailer sends plain-text e-mails to users.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
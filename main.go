@@ -3,11 +3,18 @@ package main
 import (
 	"e-ticaret-api/db"
 	"e-ticaret-api/handlers"
+	"e-ticaret-api/jwtkeys"
+	"e-ticaret-api/mailer"
 	"e-ticaret-api/middleware"
+	"e-ticaret-api/oidc"
+	"e-ticaret-api/search"
+	"e-ticaret-api/storage"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -31,9 +38,13 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		log.Fatal("JWT_SECRET_KEY env is not set")
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		log.Fatal("JWT_KEYS_DIR env is not set")
+	}
+	keys, err := jwtkeys.LoadDir(keysDir, os.Getenv("JWT_SIGNING_KEY_ID"))
+	if err != nil {
+		log.Fatal("Error loading JWT keys: ", err)
 	}
 
 	databaseUrl := os.Getenv("DATABASE_URL")
@@ -41,16 +52,73 @@ func main() {
 	defer db.Close()
 	fmt.Println("Veritabanına bağlanıldı.")
 
+	middleware.DB = db
+	middleware.Keys = keys
+
+	// SMTP ayarlanmamışsa e-postalar dosyaya/loga yazılır
+	var mail mailer.Mailer = mailer.NewLogMailer(os.Getenv("MAIL_LOG_FILE"))
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		mail = &mailer.SMTPMailer{
+			Host:     smtpHost,
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	}
+
+	providers, err := oidc.LoadProviders()
+	if err != nil {
+		log.Fatal("Error loading OIDC providers: ", err)
+	}
+
+	images, err := storage.Load()
+	if err != nil {
+		log.Fatal("Error configuring image storage: ", err)
+	}
+
 	r := mux.NewRouter()
 
-	appHandler := &handlers.AppHandler{DB: db}
+	appHandler := &handlers.AppHandler{DB: db, Mailer: mail, Keys: keys, OIDCProviders: providers, Search: search.NewMemoryIndex(), Images: images}
+	if err := appHandler.BackfillCategories(); err != nil {
+		log.Fatal("Error moving product categories: ", err)
+	}
+	if err := appHandler.BuildSearchIndex(); err != nil {
+		log.Fatal("Error building search index: ", err)
+	}
+	if err := appHandler.FailInterruptedImports(); err != nil {
+		log.Fatal("Error closing interrupted product imports: ", err)
+	}
+
+	// Hiç sipariş edilmemiş arşivlenmiş ürünler bu süreden sonra kalıcı olarak silinir
+	purgeDays := 30
+	if value := os.Getenv("ARCHIVED_PRODUCT_RETENTION_DAYS"); value != "" {
+		if purgeDays, err = strconv.Atoi(value); err != nil || purgeDays < 0 {
+			log.Fatal("Invalid ARCHIVED_PRODUCT_RETENTION_DAYS: ", value)
+		}
+	}
+	appHandler.StartProductPurge(time.Duration(purgeDays) * 24 * time.Hour)
+
+	if err := appHandler.BackfillPriceHistory(); err != nil {
+		log.Fatal("Error recording product prices: ", err)
+	}
+	appHandler.StartPriceScheduler()
+	appHandler.StartReservationExpiry()
 
 	// Swagger endpoint
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
+	// @Summary JSON Web Key Set
+	// @Description Public keys for verifying tokens issued by this API
+	// @Tags auth
+	// @Produce  json
+	// @Success 200 {object} jwtkeys.JWKS
+	// @Router /.well-known/jwks.json [get]
+	r.Handle("/.well-known/jwks.json", appHandler.JWKS()).Methods("GET")
+
 	//routes
 	// @Summary Register a new user
-	// @Description Register a new user with email, password, name, and role
+	// @Description Register a new customer account with email, password and name
 	// @Tags auth
 	// @Accept  json
 	// @Produce  json
@@ -67,12 +135,315 @@ func main() {
 	// @Accept  json
 	// @Produce  json
 	// @Param   user     body     models.User     true  "User"
-	// @Success 200 {object} map[string]string
+	// @Success 200 {object} models.TokenResponse
 	// @Failure 400 {string} string "Invalid request"
-	// @Failure 401 {string} string "Unauthorized"
+	// @Failure 401 {string} string "Invalid credentials"
+	// @Failure 429 {string} string "Too many failed login attempts"
 	// @Router /login [post]
 	r.Handle("/login", appHandler.Login()).Methods("POST")
 
+	// @Summary Complete login with a second factor
+	// @Description Exchange a login challenge token and a TOTP or recovery code for tokens
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} models.TokenResponse
+	// @Failure 401 {string} string "Invalid code"
+	// @Router /login/2fa [post]
+	r.Handle("/login/2fa", appHandler.LoginTwoFactor()).Methods("POST")
+
+	// @Summary Start social login
+	// @Description Redirect to the OpenID Connect provider using PKCE
+	// @Tags auth
+	// @Param   provider    path   string  true   "Provider name"
+	// @Param   login_hint  query  string  false  "Email hint passed to the provider"
+	// @Success 302 {string} string "Redirect to provider"
+	// @Failure 404 {string} string "Unknown provider"
+	// @Router /auth/{provider}/login [get]
+	r.Handle("/auth/{provider}/login", appHandler.OIDCLogin()).Methods("GET")
+
+	// @Summary Finish social login
+	// @Description Redeem the provider's authorization code and log in, linking by verified email or creating a customer account
+	// @Tags auth
+	// @Produce  json
+	// @Param   provider  path   string  true  "Provider name"
+	// @Param   code      query  string  true  "Authorization code"
+	// @Param   state     query  string  true  "State"
+	// @Success 200 {object} models.TokenResponse
+	// @Failure 400 {string} string "Invalid or expired state"
+	// @Failure 403 {string} string "Email not verified by provider"
+	// @Failure 409 {string} string "Account not verified"
+	// @Router /auth/{provider}/callback [get]
+	r.Handle("/auth/{provider}/callback", appHandler.OIDCCallback()).Methods("GET")
+
+	// @Summary Start two-factor enrollment
+	// @Description Generate a TOTP secret and provisioning URI for an admin or seller
+	// @Tags auth
+	// @Produce  json
+	// @Success 200 {object} models.TwoFactorEnrollment
+	// @Failure 409 {string} string "Two-factor authentication already enabled"
+	// @Router /2fa/enroll [post]
+	// @Security ApiKeyAuth
+	r.Handle("/2fa/enroll", middleware.EnrollmentMiddleware(middleware.BlockImpersonation(appHandler.EnrollTwoFactor()))).Methods("POST")
+
+	// @Summary Confirm two-factor enrollment
+	// @Description Activate two-factor authentication and receive recovery codes
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Failure 400 {string} string "Invalid code"
+	// @Router /2fa/confirm [post]
+	// @Security ApiKeyAuth
+	r.Handle("/2fa/confirm", middleware.EnrollmentMiddleware(middleware.BlockImpersonation(appHandler.ConfirmTwoFactor()))).Methods("POST")
+
+	// @Summary Disable two-factor authentication
+	// @Description Disable two-factor authentication with a valid code (not allowed for admins)
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string "Two-factor authentication disabled"
+	// @Failure 403 {string} string "Two-factor authentication is required for admins"
+	// @Router /2fa/disable [post]
+	// @Security ApiKeyAuth
+	r.Handle("/2fa/disable", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.DisableTwoFactor()))).Methods("POST")
+
+	// @Summary Refresh access token
+	// @Description Exchange a refresh token for a new access token and a rotated refresh token
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} models.TokenResponse
+	// @Failure 400 {string} string "Invalid request"
+	// @Failure 401 {string} string "Invalid refresh token"
+	// @Router /token/refresh [post]
+	r.Handle("/token/refresh", appHandler.RefreshToken()).Methods("POST")
+
+	// @Summary Logout
+	// @Description Revoke the current access token and its refresh token family
+	// @Tags auth
+	// @Produce  json
+	// @Success 200 {string} string "Logged out"
+	// @Failure 500 {string} string "Internal server error"
+	// @Router /logout [post]
+	// @Security ApiKeyAuth
+	r.Handle("/logout", middleware.JWTMiddleware(appHandler.Logout())).Methods("POST")
+
+	// @Summary Request a password reset
+	// @Description Send a single-use password reset token to the given email address
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string "If the email exists, a reset token has been sent"
+	// @Failure 400 {string} string "Invalid request"
+	// @Router /password/forgot [post]
+	r.Handle("/password/forgot", appHandler.ForgotPassword()).Methods("POST")
+
+	// @Summary Reset password
+	// @Description Set a new password using a password reset token
+	// @Tags auth
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string "Password updated"
+	// @Failure 400 {string} string "Invalid or expired token"
+	// @Router /password/reset [post]
+	r.Handle("/password/reset", appHandler.ResetPassword()).Methods("POST")
+
+	// @Summary Verify email address
+	// @Description Mark the account as verified using the token sent by email
+	// @Tags auth
+	// @Produce  json
+	// @Param   token  query  string  true  "Verification token"
+	// @Success 200 {string} string "Email verified"
+	// @Failure 400 {string} string "Invalid or expired token"
+	// @Router /verify-email [get]
+	r.Handle("/verify-email", appHandler.VerifyEmail()).Methods("GET")
+
+	// @Summary Resend verification email
+	// @Description Send a new verification token to the authenticated user's email address
+	// @Tags auth
+	// @Produce  json
+	// @Success 200 {string} string "Verification email sent"
+	// @Failure 400 {string} string "Email already verified"
+	// @Router /verify-email/resend [post]
+	// @Security ApiKeyAuth
+	r.Handle("/verify-email/resend", middleware.JWTMiddleware(appHandler.ResendVerification())).Methods("POST")
+
+	// @Summary Get own profile
+	// @Description Get the authenticated user's profile
+	// @Tags me
+	// @Produce  json
+	// @Success 200 {object} models.User
+	// @Router /me [get]
+	// @Security ApiKeyAuth
+	r.Handle("/me", middleware.JWTMiddleware(appHandler.GetMe())).Methods("GET")
+
+	// @Summary Update own profile
+	// @Description Update name and email; a new email must be verified again
+	// @Tags me
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} models.User
+	// @Failure 409 {string} string "Email already in use"
+	// @Router /me [put]
+	// @Security ApiKeyAuth
+	r.Handle("/me", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.UpdateMe()))).Methods("PUT")
+
+	// @Summary Change password
+	// @Description Change the password with the old one; other sessions are signed out
+	// @Tags me
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string "Password updated"
+	// @Failure 401 {string} string "Old password is incorrect"
+	// @Router /me/password [put]
+	// @Security ApiKeyAuth
+	r.Handle("/me/password", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.ChangePassword()))).Methods("PUT")
+
+	// @Summary List active sessions
+	// @Description List the devices the user is signed in on
+	// @Tags me
+	// @Produce  json
+	// @Success 200 {array} models.Session
+	// @Router /me/sessions [get]
+	// @Security ApiKeyAuth
+	r.Handle("/me/sessions", middleware.JWTMiddleware(appHandler.GetSessions())).Methods("GET")
+
+	// @Summary Sign out other sessions
+	// @Description Sign out every session except the current one
+	// @Tags me
+	// @Produce  json
+	// @Success 200 {string} string "Other sessions revoked"
+	// @Router /me/sessions [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/me/sessions", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.RevokeOtherSessions()))).Methods("DELETE")
+
+	// @Summary Sign out a session
+	// @Description Sign out one session; its tokens stop working immediately
+	// @Tags me
+	// @Produce  json
+	// @Param   id  path  string  true  "Session ID"
+	// @Success 200 {string} string "Session revoked"
+	// @Failure 404 {string} string "Session not found"
+	// @Router /me/sessions/{id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/me/sessions/{id}", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.RevokeSession()))).Methods("DELETE")
+
+	// @Summary Export personal data
+	// @Description Download a ZIP archive with the user's profile, addresses, cart, orders, returns and reviews
+	// @Tags me
+	// @Produce  application/zip
+	// @Success 200 {file} file "ZIP archive"
+	// @Router /me/export [get]
+	// @Security ApiKeyAuth
+	r.Handle("/me/export", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.ExportMyData()))).Methods("GET")
+
+	// @Summary Delete own account
+	// @Description Anonymize the account and delete personal data; orders are kept for accounting
+	// @Tags me
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string "Account deleted"
+	// @Failure 401 {string} string "Password is incorrect"
+	// @Router /me [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/me", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.DeleteMyAccount()))).Methods("DELETE")
+
+	// @Summary List addresses
+	// @Description Get the authenticated user's address book
+	// @Tags me
+	// @Produce  json
+	// @Success 200 {array} models.Address
+	// @Router /me/addresses [get]
+	// @Security ApiKeyAuth
+	r.Handle("/me/addresses", middleware.JWTMiddleware(appHandler.GetAddresses())).Methods("GET")
+
+	// @Summary Add an address
+	// @Description Add an address to the address book
+	// @Tags me
+	// @Accept  json
+	// @Produce  json
+	// @Param   address  body  models.Address  true  "Address"
+	// @Success 201 {object} models.Address
+	// @Router /me/addresses [post]
+	// @Security ApiKeyAuth
+	r.Handle("/me/addresses", middleware.JWTMiddleware(appHandler.CreateAddress())).Methods("POST")
+
+	// @Summary Update an address
+	// @Description Replace an address in the address book
+	// @Tags me
+	// @Accept  json
+	// @Produce  json
+	// @Param   id       path  int             true  "Address ID"
+	// @Param   address  body  models.Address  true  "Address"
+	// @Success 200 {object} models.Address
+	// @Failure 404 {string} string "Address not found"
+	// @Router /me/addresses/{id} [put]
+	// @Security ApiKeyAuth
+	r.Handle("/me/addresses/{id}", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.UpdateAddress()))).Methods("PUT")
+
+	// @Summary Delete an address
+	// @Description Remove an address from the address book
+	// @Tags me
+	// @Produce  json
+	// @Param   id  path  int  true  "Address ID"
+	// @Success 200 {string} string "Address deleted"
+	// @Failure 404 {string} string "Address not found"
+	// @Router /me/addresses/{id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/me/addresses/{id}", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.DeleteAddress()))).Methods("DELETE")
+
+	// @Summary Apply to become a seller
+	// @Description Submit a seller application for admin review
+	// @Tags seller
+	// @Accept  json
+	// @Produce  json
+	// @Param   application  body  models.SellerApplication  true  "Seller application"
+	// @Success 201 {object} models.SellerApplication
+	// @Failure 409 {string} string "Application already pending"
+	// @Router /seller/apply [post]
+	// @Security ApiKeyAuth
+	r.Handle("/seller/apply", middleware.JWTMiddleware(middleware.VerifiedMiddleware(appHandler.ApplyForSeller()))).Methods("POST")
+
+	// @Summary Get orders for the seller's products
+	// @Description Get ordered items of the seller's products (API key scope: orders:read)
+	// @Tags seller
+	// @Produce  json
+	// @Success 200 {array} models.SellerOrderItem
+	// @Router /seller/orders [get]
+	// @Security ApiKeyAuth
+	r.Handle("/seller/orders", middleware.APIKeyMiddleware("orders:read")(middleware.RoleMiddleware("seller")(appHandler.GetSellerOrders()))).Methods("GET")
+
+	// @Summary Create an API key
+	// @Description Create a scoped API key for seller integrations
+	// @Tags seller
+	// @Accept  json
+	// @Produce  json
+	// @Success 201 {object} models.APIKey
+	// @Failure 400 {string} string "Invalid request"
+	// @Router /api-keys [post]
+	// @Security ApiKeyAuth
+	r.Handle("/api-keys", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(middleware.BlockImpersonation(appHandler.CreateAPIKey())))).Methods("POST")
+
+	// @Summary List API keys
+	// @Description List the seller's API keys without their secrets
+	// @Tags seller
+	// @Produce  json
+	// @Success 200 {array} models.APIKey
+	// @Router /api-keys [get]
+	// @Security ApiKeyAuth
+	r.Handle("/api-keys", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(appHandler.GetAPIKeys()))).Methods("GET")
+
+	// @Summary Revoke an API key
+	// @Description Revoke one of the seller's API keys
+	// @Tags seller
+	// @Produce  json
+	// @Param   id  path  int  true  "API key ID"
+	// @Success 200 {string} string "API key revoked"
+	// @Failure 404 {string} string "API key not found"
+	// @Router /api-keys/{id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/api-keys/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(middleware.BlockImpersonation(appHandler.RevokeAPIKey())))).Methods("DELETE")
+
 	// @Summary Add a new product
 	// @Description Add a new product by seller
 	// @Tags products
@@ -84,10 +455,57 @@ func main() {
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /product [post]
 	// @Security ApiKeyAuth
-	r.Handle("/product", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(appHandler.AddProduct()))).Methods("POST")
+	r.Handle("/product", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller")(middleware.VerifiedMiddleware(appHandler.AddProduct())))).Methods("POST")
+
+	// @Summary Start a bulk product import
+	// @Description Upload a CSV or JSON Lines catalog; rows are validated and upserted by SKU in the background
+	// @Tags products
+	// @Accept  multipart/form-data,text/csv,application/x-ndjson
+	// @Produce  json
+	// @Param   format  query     string  false  "csv or jsonl"
+	// @Param   file    formData  file    false  "Catalog file"
+	// @Success 202 {object} models.ProductImport
+	// @Failure 400 {string} string "Invalid file"
+	// @Failure 409 {string} string "An import is already running"
+	// @Failure 413 {string} string "File too large"
+	// @Router /product/imports [post]
+	// @Security ApiKeyAuth
+	r.Handle("/product/imports", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller")(middleware.VerifiedMiddleware(appHandler.ImportProducts())))).Methods("POST")
+
+	// @Summary Get a bulk product import
+	// @Description Get the status and row counts of an import
+	// @Tags products
+	// @Produce  json
+	// @Param   id  path  int  true  "Import ID"
+	// @Success 200 {object} models.ProductImport
+	// @Failure 404 {string} string "Import not found"
+	// @Router /product/imports/{id} [get]
+	// @Security ApiKeyAuth
+	r.Handle("/product/imports/{id:[0-9]+}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller")(appHandler.GetProductImport()))).Methods("GET")
+
+	// @Summary Download the result of a bulk product import
+	// @Description Download the outcome of every row as CSV
+	// @Tags products
+	// @Produce  text/csv
+	// @Param   id  path  int  true  "Import ID"
+	// @Success 200 {file} file "Result CSV"
+	// @Failure 404 {string} string "Import not found"
+	// @Router /product/imports/{id}/result [get]
+	// @Security ApiKeyAuth
+	r.Handle("/product/imports/{id:[0-9]+}/result", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller")(appHandler.GetProductImportResult()))).Methods("GET")
+
+	// @Summary Export the seller's products
+	// @Description Stream the seller's catalog as CSV or JSON Lines in the import format
+	// @Tags products
+	// @Produce  text/csv,application/x-ndjson
+	// @Param   format  query  string  false  "csv (default) or jsonl"
+	// @Success 200 {file} file "Catalog file"
+	// @Router /product/export [get]
+	// @Security ApiKeyAuth
+	r.Handle("/product/export", middleware.APIKeyMiddleware("products:read")(middleware.RoleMiddleware("seller")(appHandler.ExportProducts()))).Methods("GET")
 
 	// @Summary Update a product
-	// @Description Update a product by seller
+	// @Description Update the seller's own product (admins: any product); only fields present in the body change
 	// @Tags products
 	// @Accept  json
 	// @Produce  json
@@ -96,39 +514,249 @@ func main() {
 	// @Success 200 {object} models.Product
 	// @Failure 400 {string} string "Invalid request"
 	// @Failure 404 {string} string "Product not found"
+	// @Failure 409 {string} string "Stock is below the quantity reserved at checkout"
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /product/{id} [put]
+	// @Router /product/{id} [patch]
 	// @Security ApiKeyAuth
-	r.Handle("/product/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(appHandler.UpdateProduct()))).Methods("PUT")
+	r.Handle("/product/{id}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.UpdateProduct()))).Methods("PUT", "PATCH")
 
 	// @Summary Delete a product
-	// @Description Delete a product by seller
+	// @Description Archive the seller's own product (admins: any product); it stays readable for past orders
 	// @Tags products
 	// @Accept  json
 	// @Produce  json
 	// @Param   id  path  int  true  "Product ID"
-	// @Success 200 {string} string "Product deleted"
+	// @Success 200 {string} string "Product archived"
 	// @Failure 400 {string} string "Invalid request"
 	// @Failure 404 {string} string "Product not found"
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /product/{id} [delete]
 	// @Security ApiKeyAuth
-	r.Handle("/product/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("seller")(appHandler.DeleteProduct()))).Methods("DELETE")
+	r.Handle("/product/{id}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(middleware.BlockImpersonation(appHandler.DeleteProduct())))).Methods("DELETE")
+
+	// @Summary Set the option types of a product
+	// @Description Replace the option types (size, color, ...) and their values of the seller's own product
+	// @Tags products
+	// @Accept  json
+	// @Produce  json
+	// @Param   id       path  int                     true  "Product ID"
+	// @Param   options  body  []models.ProductOption  true  "Options"
+	// @Success 200 {array} models.ProductOption
+	// @Failure 404 {string} string "Product not found"
+	// @Failure 409 {string} string "Product has variants"
+	// @Router /product/{id}/options [put]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/options", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.SetProductOptions()))).Methods("PUT")
+
+	// @Summary Add a variant to a product
+	// @Description Add a SKU with its own price, stock and image for one combination of option values
+	// @Tags products
+	// @Accept  json
+	// @Produce  json
+	// @Param   id       path  int                    true  "Product ID"
+	// @Param   variant  body  models.ProductVariant  true  "Variant"
+	// @Success 201 {object} models.ProductVariant
+	// @Failure 400 {string} string "Invalid variant"
+	// @Failure 409 {string} string "SKU or combination already in use"
+	// @Router /product/{id}/variants [post]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/variants", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.CreateVariant()))).Methods("POST")
+
+	// @Summary Update a variant
+	// @Description Change the SKU, price, stock or image of a variant; only fields present in the body change
+	// @Tags products
+	// @Accept  json
+	// @Produce  json
+	// @Param   id          path  int                    true  "Product ID"
+	// @Param   variant_id  path  int                    true  "Variant ID"
+	// @Param   variant     body  models.ProductVariant  true  "Variant"
+	// @Success 200 {object} models.ProductVariant
+	// @Failure 404 {string} string "Variant not found"
+	// @Failure 409 {string} string "SKU already in use or stock below the quantity reserved at checkout"
+	// @Router /product/{id}/variants/{variant_id} [patch]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/variants/{variant_id}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.UpdateVariant()))).Methods("PUT", "PATCH")
+
+	// @Summary Delete a variant
+	// @Description Delete a variant and remove it from carts
+	// @Tags products
+	// @Produce  json
+	// @Param   id          path  int  true  "Product ID"
+	// @Param   variant_id  path  int  true  "Variant ID"
+	// @Success 200 {string} string "Variant deleted"
+	// @Failure 404 {string} string "Variant not found"
+	// @Router /product/{id}/variants/{variant_id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/variants/{variant_id}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(middleware.BlockImpersonation(appHandler.DeleteVariant())))).Methods("DELETE")
+
+	// @Summary Upload product images
+	// @Description Upload JPEG, PNG or GIF images (multipart field "images", max 5 MB each); thumbnails are generated
+	// @Tags products
+	// @Accept  multipart/form-data
+	// @Produce  json
+	// @Param   id      path      int   true  "Product ID"
+	// @Param   images  formData  file  true  "Images"
+	// @Success 201 {array} models.ProductImage
+	// @Failure 413 {string} string "Image too large"
+	// @Failure 415 {string} string "Unsupported image type"
+	// @Router /product/{id}/images [post]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/images", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.UploadProductImages()))).Methods("POST")
+
+	// @Summary Reorder product images
+	// @Description Set the display order of all images of a product
+	// @Tags products
+	// @Accept  json
+	// @Produce  json
+	// @Param   id    path  int     true  "Product ID"
+	// @Param   body  body  object  true  "{\"image_ids\": [3, 1, 2]}"
+	// @Success 200 {array} models.ProductImage
+	// @Failure 400 {string} string "Invalid image order"
+	// @Router /product/{id}/images/order [put]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/images/order", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.ReorderProductImages()))).Methods("PUT")
+
+	// @Summary Set the primary product image
+	// @Description Make an image the primary image of a product
+	// @Tags products
+	// @Produce  json
+	// @Param   id        path  int  true  "Product ID"
+	// @Param   image_id  path  int  true  "Image ID"
+	// @Success 200 {array} models.ProductImage
+	// @Failure 404 {string} string "Image not found"
+	// @Router /product/{id}/images/{image_id}/primary [put]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/images/{image_id:[0-9]+}/primary", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.SetPrimaryProductImage()))).Methods("PUT")
+
+	// @Summary Delete a product image
+	// @Description Delete an image of a product
+	// @Tags products
+	// @Produce  json
+	// @Param   id        path  int  true  "Product ID"
+	// @Param   image_id  path  int  true  "Image ID"
+	// @Success 200 {string} string "Image deleted"
+	// @Failure 404 {string} string "Image not found"
+	// @Router /product/{id}/images/{image_id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/images/{image_id:[0-9]+}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(middleware.BlockImpersonation(appHandler.DeleteProductImage())))).Methods("DELETE")
+
+	// @Summary Schedule a price change
+	// @Description Schedule a future price, or a campaign price with ends_at, for a product
+	// @Tags products
+	// @Accept  json
+	// @Produce  json
+	// @Param   id        path  int                   true  "Product ID"
+	// @Param   schedule  body  models.PriceSchedule  true  "Schedule"
+	// @Success 201 {object} models.PriceSchedule
+	// @Failure 400 {string} string "Invalid schedule"
+	// @Failure 409 {string} string "Overlapping schedule"
+	// @Router /product/{id}/price-schedules [post]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/price-schedules", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.CreatePriceSchedule()))).Methods("POST")
+
+	// @Summary Get the price schedules of a product
+	// @Description Get the scheduled, running and past price changes of a product
+	// @Tags products
+	// @Produce  json
+	// @Param   id  path  int  true  "Product ID"
+	// @Success 200 {array} models.PriceSchedule
+	// @Router /product/{id}/price-schedules [get]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/price-schedules", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.GetPriceSchedules()))).Methods("GET")
+
+	// @Summary Cancel a price schedule
+	// @Description Cancel a price change that has not started yet
+	// @Tags products
+	// @Produce  json
+	// @Param   id           path  int  true  "Product ID"
+	// @Param   schedule_id  path  int  true  "Schedule ID"
+	// @Success 200 {string} string "Schedule cancelled"
+	// @Failure 409 {string} string "Schedule already started"
+	// @Router /product/{id}/price-schedules/{schedule_id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/product/{id}/price-schedules/{schedule_id:[0-9]+}", middleware.APIKeyMiddleware("products:write")(middleware.RoleMiddleware("seller", "admin")(appHandler.CancelPriceSchedule()))).Methods("DELETE")
+
+	// @Summary Get a product image
+	// @Description Serve an uploaded product image
+	// @Tags products
+	// @Produce  image/jpeg,image/png,image/gif
+	// @Param   image_id  path  int  true  "Image ID"
+	// @Success 200 {file} file "Image"
+	// @Failure 404 {string} string "Image not found"
+	// @Router /product-images/{image_id} [get]
+	r.Handle("/product-images/{image_id:[0-9]+}", appHandler.GetProductImage(false)).Methods("GET")
+
+	// @Summary Get a product image thumbnail
+	// @Description Serve the JPEG thumbnail of an uploaded product image
+	// @Tags products
+	// @Produce  image/jpeg
+	// @Param   image_id  path  int  true  "Image ID"
+	// @Success 200 {file} file "Thumbnail"
+	// @Failure 404 {string} string "Image not found"
+	// @Router /product-images/{image_id}/thumbnail [get]
+	r.Handle("/product-images/{image_id:[0-9]+}/thumbnail", appHandler.GetProductImage(true)).Methods("GET")
 
 	// @Summary Get all products
-	// @Description Get all products with optional filters
+	// @Description Get a page of products with optional filters (keyset pagination with opaque cursors)
 	// @Tags products
 	// @Accept  json
 	// @Produce  json
-	// @Param   category  query    string  false  "Category"
-	// @Param   search    query    string  false  "Search"
-	// @Param   sort_by   query    string  false  "Sort by"
-	// @Param   order     query    string  false  "Order"
-	// @Success 200 {array} models.Product
+	// @Param   category   query    string  false  "Category ID or slug (includes subcategories)"
+	// @Param   search     query    string  false  "Search"
+	// @Param   min_price  query    number  false  "Minimum price"
+	// @Param   max_price  query    number  false  "Maximum price"
+	// @Param   seller_id  query    int     false  "Seller ID"
+	// @Param   min_rating query    number  false  "Minimum average rating"
+	// @Param   in_stock   query    bool    false  "Only products with stock"
+	// @Param   sort_by    query    string  false  "id, name, price, quantity or relevance (when searching)"
+	// @Param   order      query    string  false  "asc or desc"
+	// @Param   limit      query    int     false  "Page size (max 100)"
+	// @Param   cursor     query    string  false  "next_cursor of the previous page"
+	// @Success 200 {object} models.Page{items=[]models.Product}
+	// @Failure 400 {string} string "Invalid filter"
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /products [get]
 	r.Handle("/products", appHandler.GetProducts()).Methods("GET")
 
+	// @Summary Get product facet counts
+	// @Description Count products per category, seller, price range and rating bucket for the GET /products filters
+	// @Tags products
+	// @Produce  json
+	// @Success 200 {object} models.ProductFacets
+	// @Failure 400 {string} string "Invalid filter"
+	// @Router /products/facets [get]
+	r.Handle("/products/facets", appHandler.GetProductFacets()).Methods("GET")
+
+	// @Summary Get a product
+	// @Description Get a product with seller name, average rating, review count, stock status and related products; supports ETag/If-None-Match
+	// @Tags products
+	// @Produce  json
+	// @Param   id  path  int  true  "Product ID"
+	// @Success 200 {object} models.ProductDetail
+	// @Success 304 {string} string "Not modified"
+	// @Failure 404 {string} string "Product not found"
+	// @Router /products/{id} [get]
+	r.Handle("/products/{id:[0-9]+}", appHandler.GetProduct()).Methods("GET")
+
+	// @Summary Get the price history of a product
+	// @Description Get the price changes of a product and its lowest price of the last 30 days
+	// @Tags products
+	// @Produce  json
+	// @Param   id  path  int  true  "Product ID"
+	// @Success 200 {object} models.PriceHistory
+	// @Failure 404 {string} string "Product not found"
+	// @Router /products/{id}/price-history [get]
+	r.Handle("/products/{id:[0-9]+}/price-history", appHandler.GetPriceHistory()).Methods("GET")
+
+	// @Summary Get the category tree
+	// @Description Get all categories nested under their parents
+	// @Tags categories
+	// @Produce  json
+	// @Success 200 {array} models.Category
+	// @Router /categories [get]
+	r.Handle("/categories", appHandler.GetCategories()).Methods("GET")
+
 	// @Summary Add to cart
 	// @Description Add a product to the cart
 	// @Tags cart
@@ -206,24 +834,48 @@ func main() {
 	// @Security ApiKeyAuth
 	r.Handle("/carts/remove/cart/items", middleware.JWTMiddleware(appHandler.RemoveCartItems())).Methods("DELETE")
 
+	// @Summary Begin checkout
+	// @Description Reserve the stock of the cart for 15 minutes while checkout is completed
+	// @Tags orders
+	// @Produce  json
+	// @Success 201 {object} models.StockReservation
+	// @Failure 400 {string} string "Not enough product quantity"
+	// @Failure 409 {string} string "Product is no longer available"
+	// @Router /checkout [post]
+	r.Handle("/checkout", middleware.JWTMiddleware(middleware.VerifiedMiddleware(middleware.BlockImpersonation(appHandler.BeginCheckout())))).Methods("POST")
+
+	// @Summary Cancel checkout
+	// @Description Release the stock reserved by checkout
+	// @Tags orders
+	// @Produce  json
+	// @Success 200 {string} string "Reservation released"
+	// @Failure 404 {string} string "No active checkout"
+	// @Router /checkout [delete]
+	r.Handle("/checkout", middleware.JWTMiddleware(middleware.BlockImpersonation(appHandler.CancelCheckout()))).Methods("DELETE")
+
 	// @Summary Create order
-	// @Description Create a new order from the cart items
+	// @Description Create a new order from the cart items, shipped to the given or default address
 	// @Tags orders
 	// @Accept  json
 	// @Produce  json
+	// @Param   body  body  object  false  "shipping_address_id and billing_address_id"
 	// @Success 201 {object} models.Order
 	// @Failure 400 {string} string "Invalid request"
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /order [post]
 	// @Security ApiKeyAuth
-	r.Handle("/order", middleware.JWTMiddleware(appHandler.CreateOrder())).Methods("POST")
+	r.Handle("/order", middleware.JWTMiddleware(middleware.VerifiedMiddleware(middleware.BlockImpersonation(appHandler.CreateOrder())))).Methods("POST")
 
 	// @Summary Get user orders
-	// @Description Get all orders for a user
+	// @Description Get a page of orders for a user
 	// @Tags orders
 	// @Accept  json
 	// @Produce  json
-	// @Success 200 {array} models.Order
+	// @Param   sort_by  query  string  false  "id or total_price"
+	// @Param   order    query  string  false  "asc or desc"
+	// @Param   limit    query  int     false  "Page size (max 100)"
+	// @Param   cursor   query  string  false  "next_cursor of the previous page"
+	// @Success 200 {object} models.Page{items=[]models.Order}
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /orders [get]
 	// @Security ApiKeyAuth
@@ -259,16 +911,106 @@ func main() {
 	r.Handle("/orders/{order_id}/status", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.UpdateOrderStatus()))).Methods("PUT")
 
 	// @Summary Get all users
-	// @Description Get all users by admin
+	// @Description Get a page of users by admin
 	// @Tags admin
 	// @Accept  json
 	// @Produce  json
-	// @Success 200 {array} models.User
+	// @Param   role     query  string  false  "Role"
+	// @Param   sort_by  query  string  false  "id, email or name"
+	// @Param   order    query  string  false  "asc or desc"
+	// @Param   limit    query  int     false  "Page size (max 100)"
+	// @Param   cursor   query  string  false  "next_cursor of the previous page"
+	// @Success 200 {object} models.Page{items=[]models.User}
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /admin/users [get]
 	// @Security ApiKeyAuth
 	r.Handle("/admin/users", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.GetUsers()))).Methods("GET")
 
+	// @Summary Change a user's role
+	// @Description Promote or demote a user by admin
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   id    path  int     true  "User ID"
+	// @Param   role  body  string  true  "customer, seller or admin"
+	// @Success 200 {string} string "User role updated"
+	// @Failure 400 {string} string "Invalid request"
+	// @Failure 404 {string} string "User not found"
+	// @Router /admin/users/{id}/role [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/users/{id}/role", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.UpdateUserRole()))).Methods("PUT")
+
+	// @Summary Suspend or reinstate a user
+	// @Description Suspend or reinstate a user by admin
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   id         path  int   true  "User ID"
+	// @Param   suspended  body  bool  true  "Suspended"
+	// @Success 200 {string} string "User suspension updated"
+	// @Failure 404 {string} string "User not found"
+	// @Router /admin/users/{id}/suspend [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/users/{id}/suspend", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.SuspendUser()))).Methods("PUT")
+
+	// @Summary Unlock a user account
+	// @Description Clear failed login attempts and lockout for a user by admin
+	// @Tags admin
+	// @Produce  json
+	// @Param   id  path  int  true  "User ID"
+	// @Success 200 {string} string "User unlocked"
+	// @Failure 404 {string} string "User not found"
+	// @Router /admin/users/{id}/unlock [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/users/{id}/unlock", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.UnlockUser()))).Methods("PUT")
+
+	// @Summary Impersonate a user
+	// @Description Issue a 30 minute token acting as a non-admin user for support; every request made with it is audited
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   id    path  int     true  "User ID"
+	// @Param   body  body  object  true  "{\"reason\": \"Ticket #1234\"}"
+	// @Success 200 {object} models.ImpersonationToken
+	// @Failure 403 {string} string "Admins cannot be impersonated"
+	// @Router /admin/users/{id}/impersonate [post]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/users/{id}/impersonate", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.ImpersonateUser()))).Methods("POST")
+
+	// @Summary Impersonation audit log
+	// @Description List requests made with impersonation tokens
+	// @Tags admin
+	// @Produce  json
+	// @Param   admin_id  query  int  false  "Acting admin ID"
+	// @Param   user_id   query  int  false  "Impersonated user ID"
+	// @Success 200 {array} models.ImpersonationRequest
+	// @Router /admin/impersonation-audit [get]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/impersonation-audit", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.GetImpersonationAudit()))).Methods("GET")
+
+	// @Summary Get seller applications
+	// @Description Get seller applications by admin
+	// @Tags admin
+	// @Produce  json
+	// @Param   status  query  string  false  "Status"
+	// @Success 200 {array} models.SellerApplication
+	// @Router /admin/seller-applications [get]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/seller-applications", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.GetSellerApplications()))).Methods("GET")
+
+	// @Summary Approve or reject a seller application
+	// @Description Approve or reject a pending seller application by admin
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   id      path  int     true  "Application ID"
+	// @Param   status  body  string  true  "approved or rejected"
+	// @Success 200 {string} string "Application reviewed"
+	// @Failure 404 {string} string "Application not found"
+	// @Router /admin/seller-applications/{id} [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/seller-applications/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.ReviewSellerApplication()))).Methods("PUT")
+
 	// @Summary Add a product by admin
 	// @Description Add a new product by admin
 	// @Tags admin
@@ -282,12 +1024,77 @@ func main() {
 	// @Security ApiKeyAuth
 	r.Handle("/admin/products", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.AdminAddProduct()))).Methods("POST")
 
+	// @Summary Get archived products
+	// @Description Get a page of archived products by admin
+	// @Tags admin
+	// @Produce  json
+	// @Param   seller_id  query  int     false  "Seller ID"
+	// @Param   limit      query  int     false  "Page size"
+	// @Param   cursor     query  string  false  "Cursor from the previous page"
+	// @Success 200 {object} models.Page{items=[]models.Product}
+	// @Router /admin/products/archived [get]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/products/archived", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.GetArchivedProducts()))).Methods("GET")
+
+	// @Summary Restore an archived product
+	// @Description Restore an archived product by admin
+	// @Tags admin
+	// @Produce  json
+	// @Param   id  path  int  true  "Product ID"
+	// @Success 200 {object} models.Product
+	// @Failure 404 {string} string "Archived product not found"
+	// @Router /admin/products/{id}/restore [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/products/{id}/restore", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.RestoreProduct()))).Methods("PUT")
+
+	// @Summary Create a category
+	// @Description Create a category by admin; the slug defaults to the name
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   category  body  models.Category  true  "Category"
+	// @Success 201 {object} models.Category
+	// @Failure 409 {string} string "Slug already in use"
+	// @Router /admin/categories [post]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/categories", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.CreateCategory()))).Methods("POST")
+
+	// @Summary Update a category
+	// @Description Rename, move or reorder a category by admin; only the fields sent are changed
+	// @Tags admin
+	// @Accept  json
+	// @Produce  json
+	// @Param   id  path  int  true  "Category ID"
+	// @Success 200 {object} models.Category
+	// @Failure 404 {string} string "Category not found"
+	// @Failure 409 {string} string "Slug already in use"
+	// @Router /admin/categories/{id} [put]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/categories/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.UpdateCategory()))).Methods("PUT")
+
+	// @Summary Delete a category
+	// @Description Delete an empty category by admin
+	// @Tags admin
+	// @Produce  json
+	// @Param   id  path  int  true  "Category ID"
+	// @Success 200 {string} string "Category deleted"
+	// @Failure 409 {string} string "Category is not empty"
+	// @Router /admin/categories/{id} [delete]
+	// @Security ApiKeyAuth
+	r.Handle("/admin/categories/{id}", middleware.JWTMiddleware(middleware.RoleMiddleware("admin")(appHandler.DeleteCategory()))).Methods("DELETE")
+
 	// @Summary Get all orders by admin
-	// @Description Get all orders by admin
+	// @Description Get a page of all orders by admin
 	// @Tags admin
 	// @Accept  json
 	// @Produce  json
-	// @Success 200 {array} models.Order
+	// @Param   status   query  string  false  "Order status"
+	// @Param   user_id  query  int     false  "User ID"
+	// @Param   sort_by  query  string  false  "id or total_price"
+	// @Param   order    query  string  false  "asc or desc"
+	// @Param   limit    query  int     false  "Page size (max 100)"
+	// @Param   cursor   query  string  false  "next_cursor of the previous page"
+	// @Success 200 {object} models.Page{items=[]models.Order}
 	// @Failure 500 {string} string "Internal server error"
 	// @Router /admin/orders [get]
 	// @Security ApiKeyAuth
@@ -326,12 +1133,16 @@ func main() {
 	r.Handle("/reviews", middleware.JWTMiddleware(appHandler.CreateReview())).Methods("POST")
 
 	// @Summary Get reviews for a product
-	// @Description Get all reviews for a specific product
+	// @Description Get a page of reviews for a specific product
 	// @Tags Reviews
 	// @Accept json
 	// @Produce json
 	// @Param product_id path int true "Product ID"
-	// @Success 200 {array} models.Review
+	// @Param sort_by query string false "id or rating"
+	// @Param order query string false "asc or desc"
+	// @Param limit query int false "Page size (max 100)"
+	// @Param cursor query string false "next_cursor of the previous page"
+	// @Success 200 {object} models.Page{items=[]models.Review}
 	// @Router /reviews/{product_id} [get]
 	r.Handle("/reviews/{product_id}", appHandler.GetReviews()).Methods("GET")
 
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"e-ticaret-api/models"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so they can be told apart from JWTs.
+const APIKeyPrefix = "etk_"
+
+// APIKeyMiddleware authenticates either a seller API key that carries scope or,
+// when no API key is sent, a bearer JWT. Keys are read from the X-API-Key
+// header or from "Authorization: Bearer etk_...".
+func APIKeyMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		jwtHandler := JWTMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+			if !strings.HasPrefix(key, APIKeyPrefix) {
+				jwtHandler.ServeHTTP(w, r)
+				return
+			}
+
+			userID, scopes, err := authenticateAPIKey(key)
+			if err == sql.ErrNoRows {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Error checking API key", http.StatusInternalServerError)
+				return
+			}
+			if !hasScope(scopes, scope) {
+				http.Error(w, "API key lacks scope "+scope, http.StatusForbidden)
+				return
+			}
+
+			var email, role string
+			var suspended bool
+			if err := DB.QueryRow("SELECT email, role, suspended FROM users WHERE id = ?", userID).Scan(&email, &role, &suspended); err != nil || suspended || role != "seller" {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			claims := &models.Claims{Username: email, UserID: userID, Role: role}
+			ctx := context.WithValue(r.Context(), "userID", userID)
+			ctx = context.WithValue(ctx, "role", role)
+			ctx = context.WithValue(ctx, "claims", claims)
+			ctx = context.WithValue(ctx, "scopes", scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticateAPIKey looks the key up by its prefix, compares the hash and
+// records the use. It returns sql.ErrNoRows for unknown, revoked or expired keys.
+func authenticateAPIKey(key string) (int, []string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 {
+		return 0, nil, sql.ErrNoRows
+	}
+
+	var (
+		keyID     int
+		userID    int
+		keyHash   string
+		scopes    string
+		expiresAt sql.NullTime
+		revokedAt sql.NullTime
+	)
+	err := DB.QueryRow("SELECT id, user_id, key_hash, scopes, expires_at, revoked_at FROM api_keys WHERE prefix = ?", APIKeyPrefix+parts[0]).
+		Scan(&keyID, &userID, &keyHash, &scopes, &expiresAt, &revokedAt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(keyHash)) != 1 {
+		return 0, nil, sql.ErrNoRows
+	}
+	now := time.Now()
+	if revokedAt.Valid || (expiresAt.Valid && now.After(expiresAt.Time)) {
+		return 0, nil, sql.ErrNoRows
+	}
+
+	DB.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, keyID)
+	return userID, strings.Split(scopes, ","), nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
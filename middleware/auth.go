@@ -2,40 +2,166 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"e-ticaret-api/jwtkeys"
 	"e-ticaret-api/models"
+	"encoding/hex"
+	"errors"
 	"net/http"
-	"os"
-
-	"github.com/dgrijalva/jwt-go"
+	"time"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
+// lastSeenInterval limits how often a session's last seen time is written.
+const lastSeenInterval = time.Minute
 
-func JWTMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "Missing token", http.StatusUnauthorized)
-			return
-		}
+// DB is used to look up revoked tokens; it is set from main.
+var DB *sql.DB
 
-		// "Bearer " ön ekini kaldır
-		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-			tokenString = tokenString[7:]
-		}
+// Keys verifies token signatures; it is set from main.
+var Keys *jwtkeys.KeySet
 
-		claims := &models.Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+var (
+	errMissingToken = errors.New("Missing token")
+	errInvalidToken = errors.New("Invalid token")
+	errRevokedToken = errors.New("Token revoked")
+	errCheckFailed  = errors.New("Error checking token")
+	errInvalidCSRF  = errors.New("Invalid CSRF token")
+)
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+func JWTMiddleware(next http.Handler) http.Handler {
+	return tokenMiddleware(next, "")
+}
+
+// EnrollmentMiddleware accepts access tokens as well as the limited tokens
+// issued to admins who must enroll in two-factor authentication before login.
+func EnrollmentMiddleware(next http.Handler) http.Handler {
+	return tokenMiddleware(next, "", "2fa_enroll")
+}
+
+func tokenMiddleware(next http.Handler, purposes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := authenticate(r, purposes)
+		if err != nil {
+			status := http.StatusUnauthorized
+			switch err {
+			case errCheckFailed:
+				status = http.StatusInternalServerError
+			case errInvalidCSRF:
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
 		ctx = context.WithValue(ctx, "role", claims.Role)
+		ctx = context.WithValue(ctx, "claims", claims)
+		if claims.ImpersonatorID != 0 {
+			serveImpersonated(w, r.WithContext(ctx), claims, next)
+			return
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticate validates the bearer token, or the "token" cookie when no
+// Authorization header is sent, and checks that its purpose is one of purposes.
+func authenticate(r *http.Request, purposes []string) (*models.Claims, error) {
+	tokenString := r.Header.Get("Authorization")
+	fromCookie := false
+	if tokenString == "" {
+		cookie, err := r.Cookie("token")
+		if err != nil || cookie.Value == "" {
+			return nil, errMissingToken
+		}
+		tokenString = cookie.Value
+		fromCookie = true
+	}
+
+	// "Bearer " ön ekini kaldır
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+
+	claims := &models.Claims{}
+	if err := Keys.Parse(tokenString, claims); err != nil || claims.Id == "" {
+		return nil, errInvalidToken
+	}
+
+	allowed := false
+	for _, purpose := range purposes {
+		if claims.Purpose == purpose {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, errInvalidToken
+	}
+
+	revoked, err := isRevoked(claims.Id)
+	if err != nil {
+		return nil, errCheckFailed
+	}
+	if revoked {
+		return nil, errRevokedToken
+	}
+
+	// Challenge ve taklit tokenlarının oturumu yoktur, sadece header ile kabul edilir
+	if claims.SessionID == "" {
+		if fromCookie {
+			return nil, errInvalidToken
+		}
+		return claims, nil
+	}
+	csrfHash, err := checkSession(claims.SessionID)
+	if err == sql.ErrNoRows {
+		return nil, errRevokedToken
+	}
+	if err != nil {
+		return nil, errCheckFailed
+	}
+
+	// Tarayıcı çerezi kendiliğinden gönderdiği için durum değiştiren isteklerde CSRF tokenı aranır
+	if fromCookie && !safeMethod(r.Method) {
+		sum := sha256.Sum256([]byte(r.Header.Get("X-CSRF-Token")))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(csrfHash)) != 1 {
+			return nil, errInvalidCSRF
+		}
+	}
+	return claims, nil
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// checkSession returns the CSRF token hash of an active session and records that
+// it was seen. It returns sql.ErrNoRows when the session was signed out.
+func checkSession(sessionID string) (string, error) {
+	var csrfHash string
+	var lastSeenAt time.Time
+	err := DB.QueryRow("SELECT csrf_hash, last_seen_at FROM sessions WHERE id = ? AND revoked_at IS NULL", sessionID).Scan(&csrfHash, &lastSeenAt)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if now.Sub(lastSeenAt) > lastSeenInterval {
+		// Son görülme zamanı en iyi çabayla güncellenir, hata isteği durdurmaz
+		DB.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", now, sessionID)
+	}
+	return csrfHash, nil
+}
+
+// isRevoked reports whether the token with the given jti was revoked by logout.
+func isRevoked(jti string) (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
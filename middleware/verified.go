@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// VerifiedMiddleware rejects requests from accounts whose email address is not verified yet.
+func VerifiedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		var verified bool
+		if err := DB.QueryRow("SELECT verified FROM users WHERE id = ?", userID).Scan(&verified); err != nil {
+			http.Error(w, "User not found", http.StatusUnauthorized)
+			return
+		}
+		if !verified {
+			http.Error(w, "Email address is not verified", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Address represents a shipping or billing address in a user's address book.
+// @Description Kullanıcının adres defterindeki adresi temsil eder
+type Address struct {
+	ID                int       `json:"id" example:"1"`
+	UserID            int       `json:"user_id" example:"1"`
+	Title             string    `json:"title" example:"Ev"`
+	FullName          string    `json:"full_name" example:"John Doe"`
+	Phone             string    `json:"phone" example:"+905551112233"`
+	Line1             string    `json:"line1" example:"Atatürk Cad. No:1"`
+	Line2             string    `json:"line2" example:"Daire 3"`
+	District          string    `json:"district" example:"Kadıköy"`
+	City              string    `json:"city" example:"İstanbul"`
+	PostalCode        string    `json:"postal_code" example:"34710"`
+	Country           string    `json:"country" example:"TR"`
+	IsDefaultShipping bool      `json:"is_default_shipping" example:"true"`
+	IsDefaultBilling  bool      `json:"is_default_billing" example:"true"`
+	CreatedAt         time.Time `json:"created_at"`
+}
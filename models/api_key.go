@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey represents a seller API key used by integrations instead of a login token.
+// @Description Satıcı entegrasyonları için API anahtarını temsil eder
+type APIKey struct {
+	ID     int      `json:"id" example:"1"`
+	Name   string   `json:"name" example:"ERP sync"`
+	Prefix string   `json:"prefix" example:"etk_1a2b3c4d"`
+	Scopes []string `json:"scopes" example:"products:write,orders:read"`
+	// Key is the full secret and is only returned once, when the key is created.
+	Key        string     `json:"key,omitempty" example:"etk_1a2b3c4d_..."`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Category is a node of the product category tree.
+// @Description Ürün kategori ağacındaki bir kategoriyi temsil eder
+type Category struct {
+	ID       int    `json:"id" example:"3"`
+	ParentID *int   `json:"parent_id,omitempty" example:"1"`
+	Name     string `json:"name" example:"Elektronik"`
+	Slug     string `json:"slug" example:"elektronik"`
+	// Position orders siblings; lower comes first.
+	Position int        `json:"position" example:"0"`
+	Children []Category `json:"children,omitempty"`
+}
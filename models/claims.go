@@ -5,8 +5,13 @@ import "github.com/dgrijalva/jwt-go"
 // Claims represents the JWT claims.
 // @Description JWT iddialarını temsil eder
 type Claims struct {
-	Username string `json:"username" example:"user@example.com"` 
-	UserID   int    `json:"userID" example:"1"`                  
-	Role     string `json:"role" example:"seller"`               
+	Username  string `json:"username" example:"user@example.com"`
+	UserID    int    `json:"userID" example:"1"`
+	Role      string `json:"role" example:"seller"`
+	SessionID string `json:"sid" example:"9b1deb4d3b7d4bad"`
+	// Purpose is empty for access tokens and set for limited tokens such as 2FA challenges.
+	Purpose string `json:"purpose,omitempty" example:"2fa"`
+	// ImpersonatorID is the admin acting as UserID; it is only set on impersonation tokens.
+	ImpersonatorID int `json:"impersonator_id,omitempty" example:"1"`
 	jwt.StandardClaims
 }
@@ -0,0 +1,41 @@
+package models
+
+// ProductFacets holds the number of products per filter value for a product listing.
+// @Description Ürün listesindeki filtre seçeneklerinin ürün sayılarını temsil eder
+type ProductFacets struct {
+	Total       int               `json:"total" example:"59"`
+	Categories  []CategoryFacet   `json:"categories"`
+	Sellers     []SellerFacet     `json:"sellers"`
+	PriceRanges []PriceRangeFacet `json:"price_ranges"`
+	Ratings     []RatingFacet     `json:"ratings"`
+}
+
+// CategoryFacet is the number of products in a category, including its subcategories.
+type CategoryFacet struct {
+	CategoryID int    `json:"category_id" example:"3"`
+	ParentID   *int   `json:"parent_id,omitempty" example:"1"`
+	Name       string `json:"name" example:"Electronics"`
+	Slug       string `json:"slug" example:"electronics"`
+	Count      int    `json:"count" example:"42"`
+}
+
+// SellerFacet is the number of products of a seller.
+type SellerFacet struct {
+	SellerID int    `json:"seller_id" example:"3"`
+	Name     string `json:"name" example:"Acme Store"`
+	Count    int    `json:"count" example:"17"`
+}
+
+// PriceRangeFacet is the number of products priced from Min up to, but not including, Max.
+// Max is omitted for the last, open-ended range.
+type PriceRangeFacet struct {
+	Min   float64  `json:"min" example:"100"`
+	Max   *float64 `json:"max,omitempty" example:"250"`
+	Count int      `json:"count" example:"8"`
+}
+
+// RatingFacet is the number of products whose average rating is at least MinRating.
+type RatingFacet struct {
+	MinRating int `json:"min_rating" example:"4"`
+	Count     int `json:"count" example:"21"`
+}
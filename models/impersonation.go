@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ImpersonationToken is returned to an admin who starts impersonating a user.
+// @Description Yöneticinin bir kullanıcı adına işlem yapmasını sağlayan geçici tokenı temsil eder
+type ImpersonationToken struct {
+	Token     string `json:"token" example:"eyJhbGciOiJSUzI1NiIs..."`
+	UserID    int    `json:"user_id" example:"42"`
+	ExpiresAt int64  `json:"expires_at" example:"1718000000"`
+}
+
+// ImpersonationRequest is an audit record of a request made with an impersonation token.
+// @Description Kullanıcı adına yapılan bir isteğin denetim kaydını temsil eder
+type ImpersonationRequest struct {
+	ID              int       `json:"id" example:"1"`
+	ImpersonationID string    `json:"impersonation_id" example:"4f2a9c..."`
+	AdminID         int       `json:"admin_id" example:"1"`
+	UserID          int       `json:"user_id" example:"42"`
+	Method          string    `json:"method" example:"GET"`
+	Path            string    `json:"path" example:"/cart"`
+	Status          int       `json:"status" example:"200"`
+	IP              string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -5,19 +5,34 @@ import "time"
 // Order represents an order in the system.
 // @Description Sipariş modelini temsil eder
 type Order struct {
-	ID         int       `json:"id" example:"1"`               
-	UserID     int       `json:"user_id" example:"1"`          
-	TotalPrice float64   `json:"total_price" example:"199.99"` 
-	CreatedAt  time.Time `json:"created_at"`                   
-	Status     string    `json:"status" example:"pending"`     
+	ID         int       `json:"id" example:"1"`
+	UserID     int       `json:"user_id" example:"1"`
+	TotalPrice float64   `json:"total_price" example:"199.99"`
+	CreatedAt  time.Time `json:"created_at"`
+	Status     string    `json:"status" example:"pending"`
+	// ShippingAddress and BillingAddress are copies taken from the address book when the order is placed.
+	ShippingAddressID int    `json:"shipping_address_id,omitempty" example:"1"`
+	ShippingAddress   string `json:"shipping_address,omitempty"`
+	BillingAddress    string `json:"billing_address,omitempty"`
 }
 
 // OrderItem represents an item in an order.
 // @Description Sipariş öğesi modelini temsil eder
 type OrderItem struct {
-	ID        int     `json:"id" example:"1"`         
-	OrderID   int     `json:"order_id" example:"1"`   
-	ProductID int     `json:"product_id" example:"1"` 
-	Quantity  int     `json:"quantity" example:"2"`   
-	Price     float64 `json:"price" example:"99.99"`  
+	ID        int `json:"id" example:"1"`
+	OrderID   int `json:"order_id" example:"1"`
+	ProductID int `json:"product_id" example:"1"`
+	// VariantID and SKU identify the variant ordered; SKU is copied so it survives variant changes.
+	VariantID *int    `json:"variant_id,omitempty" example:"4"`
+	SKU       string  `json:"sku,omitempty" example:"TSHIRT-RED-M"`
+	Quantity  int     `json:"quantity" example:"2"`
+	Price     float64 `json:"price" example:"99.99"`
+}
+
+// SellerOrderItem represents an ordered item of a seller's product together with its order status.
+// @Description Satıcının ürününe ait sipariş öğesini temsil eder
+type SellerOrderItem struct {
+	OrderItem
+	Status    string    `json:"status" example:"pending"`
+	CreatedAt time.Time `json:"created_at"`
 }
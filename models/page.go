@@ -0,0 +1,11 @@
+package models
+
+// Page is the envelope of paginated list responses. NextCursor is empty on the
+// last page; pass it as the cursor query parameter to get the next page.
+// @Description Sayfalanmış liste cevaplarını temsil eder
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty" example:"eyJzIjoicHJpY2UiLCJ2IjoxOS45OSwiaWQiOjQyfQ"`
+	Total      int         `json:"total" example:"120"`
+	Limit      int         `json:"limit" example:"20"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// PriceChange is one entry of a product's price history.
+// @Description Ürünün fiyat geçmişindeki bir değişikliği temsil eder
+type PriceChange struct {
+	Price float64 `json:"price" example:"19.99"`
+	// Source is initial, manual, import, variant or scheduled.
+	Source    string    `json:"source" example:"manual"`
+	ChangedAt time.Time `json:"changed_at" example:"2024-06-01T10:00:00Z"`
+}
+
+// PriceHistory is the price history of a product, newest change first.
+// @Description Ürünün fiyat geçmişini ve son 30 günün en düşük fiyatını temsil eder
+type PriceHistory struct {
+	ProductID int     `json:"product_id" example:"1"`
+	Price     float64 `json:"price" example:"17.99"`
+	// LowestPrice30Days is the lowest price the product had in the last 30 days, including the current price.
+	LowestPrice30Days float64       `json:"lowest_price_30_days" example:"17.99"`
+	Changes           []PriceChange `json:"changes"`
+}
+
+// PriceSchedule is a future price change of a product. With EndsAt it is a
+// campaign: the previous price is restored when it ends.
+// @Description Ürünün ileri tarihli fiyat değişikliğini temsil eder
+type PriceSchedule struct {
+	ID        int        `json:"id" example:"1"`
+	ProductID int        `json:"product_id" example:"1"`
+	Price     float64    `json:"price" example:"14.99"`
+	StartsAt  time.Time  `json:"starts_at" example:"2024-06-10T00:00:00Z"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" example:"2024-06-17T00:00:00Z"`
+	// PreviousPrice is the price replaced when the schedule was applied.
+	PreviousPrice *float64 `json:"previous_price,omitempty" example:"19.99"`
+	// Status is scheduled, active (a running campaign), completed or cancelled.
+	Status    string    `json:"status" example:"scheduled"`
+	CreatedAt time.Time `json:"created_at" example:"2024-06-01T10:00:00Z"`
+}
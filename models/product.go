@@ -1,14 +1,22 @@
 package models
 
+import "time"
+
 // Product represents a product in the system.
 // @Description Ürün modelini temsil eder
 type Product struct {
-	ID          int     `json:"id" example:"1"`                    
-	Name        string  `json:"name" example:"Product Name"`       
-	Description string  `json:"description" example:"Description"` 
-	Quantity    int     `json:"quantity" example:"100"`            
-	Price       float64 `json:"price" example:"19.99"`             
-	SellerID    int     `json:"seller_id" example:"1"`             
-	Category    string  `json:"category" example:"Electronics"`    
-	ImageURL    string  `json:"image_url" example:"http://..."`    
+	ID          int     `json:"id" example:"1"`
+	Name        string  `json:"name" example:"Product Name"`
+	Description string  `json:"description" example:"Description"`
+	Quantity    int     `json:"quantity" example:"100"`
+	Price       float64 `json:"price" example:"19.99"`
+	SellerID    int     `json:"seller_id" example:"1"`
+	// SKU is the seller's own stock code, unique among the seller's products; bulk imports match products by it.
+	SKU        string `json:"sku,omitempty" example:"KBL-USB-C-1M"`
+	CategoryID int    `json:"category_id" example:"3"`
+	// Category is the name of the category; it is filled in responses and ignored in requests.
+	Category string `json:"category" example:"Electronics"`
+	ImageURL string `json:"image_url" example:"http://..."`
+	// ArchivedAt is set when the product was deleted; archived products stay readable for past orders.
+	ArchivedAt *time.Time `json:"archived_at,omitempty" example:"2024-06-01T10:00:00Z"`
 }
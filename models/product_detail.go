@@ -0,0 +1,20 @@
+package models
+
+// ProductDetail is a product with the data a product page needs.
+// @Description Ürün sayfası için ürün detayını temsil eder
+type ProductDetail struct {
+	Product
+	SellerName    string  `json:"seller_name" example:"Acme Store"`
+	AverageRating float64 `json:"average_rating" example:"4.35"`
+	ReviewCount   int     `json:"review_count" example:"12"`
+	// LowestPrice30Days is the lowest price of the last 30 days, shown next to discounts.
+	LowestPrice30Days float64 `json:"lowest_price_30_days" example:"17.99"`
+	// StockStatus is in_stock, low_stock, out_of_stock or discontinued for archived products.
+	StockStatus string `json:"stock_status" example:"in_stock"`
+	// Images are ordered by position; the primary image is also the product's image_url.
+	Images []ProductImage `json:"images"`
+	// Options and Variants are only present for products sold in variants.
+	Options  []ProductOption  `json:"options,omitempty"`
+	Variants []ProductVariant `json:"variants,omitempty"`
+	Related  []Product        `json:"related"`
+}
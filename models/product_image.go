@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProductImage is an uploaded product image. URL serves the original and ThumbnailURL a small JPEG copy.
+// @Description Ürün görselini temsil eder
+type ProductImage struct {
+	ID           int       `json:"id" example:"1"`
+	ProductID    int       `json:"product_id" example:"1"`
+	URL          string    `json:"url" example:"/product-images/1"`
+	ThumbnailURL string    `json:"thumbnail_url" example:"/product-images/1/thumbnail"`
+	ContentType  string    `json:"content_type" example:"image/jpeg"`
+	Width        int       `json:"width" example:"1200"`
+	Height       int       `json:"height" example:"900"`
+	Position     int       `json:"position" example:"0"`
+	Primary      bool      `json:"primary" example:"true"`
+	CreatedAt    time.Time `json:"created_at"`
+}
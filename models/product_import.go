@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ProductImport is an asynchronous bulk product import of a seller.
+// @Description Toplu ürün içe aktarma işini temsil eder
+type ProductImport struct {
+	ID       int    `json:"id" example:"1"`
+	SellerID int    `json:"seller_id" example:"3"`
+	Format   string `json:"format" example:"csv"`
+	// Status is pending, processing, completed or failed.
+	Status    string `json:"status" example:"completed"`
+	TotalRows int    `json:"total_rows" example:"1200"`
+	Created   int    `json:"created" example:"800"`
+	Updated   int    `json:"updated" example:"390"`
+	Failed    int    `json:"failed" example:"10"`
+	// Error explains why a failed import could not be processed at all; row errors are in the result.
+	Error      string     `json:"error,omitempty"`
+	ResultURL  string     `json:"result_url" example:"/product/imports/1/result"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
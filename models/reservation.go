@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// StockReservation holds the stock of a user's cart while checkout is in progress.
+// @Description Ödeme sırasında sepet için ayrılan stoğu temsil eder
+type StockReservation struct {
+	ID     int `json:"id" example:"1"`
+	UserID int `json:"user_id" example:"1"`
+	// Status is active, completed (an order was placed), released (checkout was cancelled) or expired.
+	Status    string                 `json:"status" example:"active"`
+	ExpiresAt time.Time              `json:"expires_at" example:"2024-06-01T10:15:00Z"`
+	CreatedAt time.Time              `json:"created_at" example:"2024-06-01T10:00:00Z"`
+	Items     []StockReservationItem `json:"items"`
+}
+
+// StockReservationItem is the quantity of a product or variant held by a reservation.
+type StockReservationItem struct {
+	ProductID int  `json:"product_id" example:"1"`
+	VariantID *int `json:"variant_id,omitempty" example:"4"`
+	Quantity  int  `json:"quantity" example:"2"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SellerApplication represents a customer's request to become a seller.
+// @Description Satıcılık başvurusunu temsil eder
+type SellerApplication struct {
+	ID          int        `json:"id" example:"1"`
+	UserID      int        `json:"user_id" example:"1"`
+	CompanyName string     `json:"company_name" example:"Acme Ltd."`
+	TaxNumber   string     `json:"tax_number" example:"1234567890"`
+	Message     string     `json:"message" example:"We sell handmade furniture"`
+	Status      string     `json:"status" example:"pending"` // pending, approved, rejected
+	CreatedAt   time.Time  `json:"created_at"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy  *int       `json:"reviewed_by,omitempty"`
+}
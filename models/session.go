@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is a signed-in device. Its ID is the "sid" claim of the access tokens issued to it.
+// @Description Oturum açılmış bir cihazı temsil eder
+type Session struct {
+	ID         string    `json:"id" example:"9b1deb4d3b7d4bad"`
+	Device     string    `json:"device" example:"Chrome on Windows"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (Windows NT 10.0; Win64; x64) ..."`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// Current is true for the session that made the request.
+	Current bool `json:"current" example:"true"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// TokenResponse represents the tokens returned after a successful login.
+// @Description Giriş sonrası dönen erişim ve yenileme tokenlarını temsil eder
+type TokenResponse struct {
+	Token        string `json:"token" example:"eyJhbGciOiJIUzI1NiIs..."`
+	RefreshToken string `json:"refresh_token" example:"3f9c2a..."`
+	ExpiresAt    int64  `json:"expires_at" example:"1718000000"`
+	// CSRFToken must be sent in the X-CSRF-Token header when authenticating with cookies.
+	CSRFToken string `json:"csrf_token" example:"8d1e4b..."`
+}
+
+// LoginChallenge is returned by login instead of tokens when a second factor is needed.
+// @Description İkinci doğrulama adımı gerektiğinde dönen geçici tokenı temsil eder
+type LoginChallenge struct {
+	TwoFactorRequired  bool   `json:"two_factor_required" example:"true"`
+	EnrollmentRequired bool   `json:"enrollment_required" example:"false"`
+	ChallengeToken     string `json:"challenge_token" example:"eyJhbGciOiJIUzI1NiIs..."`
+	ExpiresAt          int64  `json:"expires_at" example:"1718000000"`
+}
+
+// TwoFactorEnrollment contains the secret and provisioning URI for an authenticator app.
+// @Description İki adımlı doğrulama kurulum bilgilerini temsil eder
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioning_uri" example:"otpauth://totp/E-Ticaret:user@example.com?secret=..."`
+}
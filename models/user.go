@@ -3,9 +3,13 @@ package models
 // User represents a user in the system.
 // @Description Kullanıcı modelini temsil eder
 type User struct {
-	ID       int    `json:"id" example:"1"`                   
-	Email    string `json:"email" example:"user@example.com"` 
-	Password string `json:"password"`                         
-	Name     string `json:"name" example:"John Doe"`          
-	Role     string `json:"role" example:"seller"`            
+	ID        int    `json:"id" example:"1"`
+	Email     string `json:"email" example:"user@example.com"`
+	Password  string `json:"password,omitempty"`
+	Name      string `json:"name" example:"John Doe"`
+	Role      string `json:"role" example:"seller"`
+	Verified  bool   `json:"verified" example:"true"`
+	Suspended bool   `json:"suspended" example:"false"`
+	// TwoFactorEnabled reports whether TOTP two-factor authentication is active.
+	TwoFactorEnabled bool `json:"two_factor_enabled" example:"false"`
 }
@@ -0,0 +1,22 @@
+package models
+
+// ProductOption is an option type of a product, such as size or color, with its possible values.
+// @Description Ürün seçenek tipini (beden, renk gibi) ve değerlerini temsil eder
+type ProductOption struct {
+	ID     int      `json:"id" example:"1"`
+	Name   string   `json:"name" example:"Beden"`
+	Values []string `json:"values" example:"S,M,L"`
+}
+
+// ProductVariant is a purchasable combination of option values with its own SKU, price, stock and image.
+// @Description Ürün varyantını (SKU) temsil eder
+type ProductVariant struct {
+	ID        int     `json:"id" example:"1"`
+	ProductID int     `json:"product_id" example:"1"`
+	SKU       string  `json:"sku" example:"TSHIRT-RED-M"`
+	Price     float64 `json:"price" example:"249.90"`
+	Quantity  int     `json:"quantity" example:"12"`
+	ImageURL  string  `json:"image_url" example:"http://..."`
+	// Options maps each option name of the product to the value of this variant.
+	Options map[string]string `json:"options"`
+}
@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const mockKeyID = "mock"
+
+// MockProvider is a minimal OpenID Connect provider for local runs. It signs in
+// a fixed user without asking for credentials; the login_hint parameter of the
+// authorization request overrides the email so several accounts can be tried.
+type MockProvider struct {
+	Issuer        string
+	ClientID      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	expiresAt     time.Time
+}
+
+// NewMockProvider returns a mock provider with a freshly generated signing key.
+func NewMockProvider(issuer, clientID string) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockProvider{
+		Issuer:        issuer,
+		ClientID:      clientID,
+		Subject:       "mock-user-1",
+		Email:         "mock.user@example.com",
+		EmailVerified: true,
+		Name:          "Mock User",
+		key:           key,
+		codes:         make(map[string]mockCode),
+	}, nil
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, map[string]string{
+			"issuer":                 m.Issuer,
+			"authorization_endpoint": m.Issuer + "/authorize",
+			"token_endpoint":         m.Issuer + "/token",
+			"jwks_uri":               m.Issuer + "/jwks",
+		})
+	case "/jwks":
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code, err := RandomString(16)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	email := m.Email
+	if hint := q.Get("login_hint"); hint != "" {
+		email = hint
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockCode{
+		redirectURI:   redirectURI.String(),
+		codeChallenge: q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		email:         email,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	v := redirectURI.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	redirectURI.RawQuery = v.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != m.ClientID {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") ||
+		subtle.ConstantTimeCompare([]byte(challenge), []byte(code.codeChallenge)) != 1 {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	subject := m.Subject
+	if code.email != m.Email {
+		subject = "mock-" + code.email
+	}
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.Issuer,
+		"aud":            m.ClientID,
+		"sub":            subject,
+		"email":          code.email,
+		"email_verified": m.EmailVerified,
+		"name":           m.Name,
+		"nonce":          code.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = mockKeyID
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,318 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// against configurable providers, including ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Provider is a single OpenID Connect identity provider.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client *http.Client
+
+	mu                    sync.Mutex
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	keys                  map[string]*rsa.PublicKey
+}
+
+// Identity is the verified result of a login at a provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// LoadProviders builds providers from the environment. OIDC_PROVIDERS is a
+// comma-separated list of names; each name NAME is configured with
+// OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET and
+// OIDC_NAME_REDIRECT_URL.
+func LoadProviders() (map[string]*Provider, error) {
+	providers := make(map[string]*Provider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := &Provider{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       []string{"openid", "email", "profile"},
+			client:       &http.Client{Timeout: 10 * time.Second},
+		}
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge (RFC 7636).
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// discover loads the provider metadata once.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokenEndpoint != "" {
+		return nil
+	}
+
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Issuer {
+		return fmt.Errorf("discovery: issuer mismatch %q", metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return errors.New("discovery: incomplete provider metadata")
+	}
+
+	p.authorizationEndpoint = metadata.AuthorizationEndpoint
+	p.tokenEndpoint = metadata.TokenEndpoint
+	p.jwksURI = metadata.JWKSURI
+	return nil
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to. loginHint is optional.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge, loginHint string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.ClientID)
+	v.Set("redirect_uri", p.RedirectURL)
+	v.Set("scope", strings.Join(p.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+	if loginHint != "" {
+		v.Set("login_hint", loginHint)
+	}
+
+	sep := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.authorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange redeems the authorization code and verifies the returned ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (p *Provider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}}
+	_, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("id_token: %w", err)
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.Issuer {
+		return nil, errors.New("id_token: wrong issuer")
+	}
+	if !audienceContains(claims["aud"], p.ClientID) {
+		return nil, errors.New("id_token: wrong audience")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id_token: missing exp")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("id_token: nonce mismatch")
+	}
+
+	identity := &Identity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("id_token: missing sub")
+	}
+	return identity, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, _ := a.(string); s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publicKey returns the provider key with the given kid, refetching the JWKS
+// once when the kid is unknown so provider key rotation is picked up.
+func (p *Provider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
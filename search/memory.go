@@ -0,0 +1,227 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field weights: a word in the name counts more than one in the description.
+const (
+	nameWeight        = 3.0
+	categoryWeight    = 2.0
+	descriptionWeight = 1.0
+)
+
+// Weights of the ways a query word can match an indexed word.
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.7
+	fuzzyMatch  = 0.5
+)
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// maxExpansions caps how many indexed words one prefix or fuzzy query word may match.
+const maxExpansions = 50
+
+// MemoryIndex is an in-memory inverted index ranked with BM25. Query words match
+// indexed words exactly, as a prefix ("kab" → "kablo") or with typos
+// ("kablso" → "kablo"). It is safe for concurrent use.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]float64 // word → document → weighted frequency
+	docTerms map[int][]string
+	docLen   map[int]float64
+	totalLen float64
+
+	vocab      []string // sorted words, rebuilt lazily for prefix and fuzzy lookups
+	vocabDirty bool
+}
+
+// NewMemoryIndex returns an empty index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings: make(map[string]map[int]float64),
+		docTerms: make(map[int][]string),
+		docLen:   make(map[int]float64),
+	}
+}
+
+func (idx *MemoryIndex) Index(doc Document) error {
+	freqs := make(map[string]float64)
+	length := 0.0
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{{doc.Name, nameWeight}, {doc.Category, categoryWeight}, {doc.Description, descriptionWeight}} {
+		for _, term := range Tokenize(field.text) {
+			freqs[term] += field.weight
+			length += field.weight
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ID)
+
+	terms := make([]string, 0, len(freqs))
+	for term, freq := range freqs {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[int]float64)
+			idx.postings[term] = docs
+			idx.vocabDirty = true
+		}
+		docs[doc.ID] = freq
+		terms = append(terms, term)
+	}
+	idx.docTerms[doc.ID] = terms
+	idx.docLen[doc.ID] = length
+	idx.totalLen += length
+	return nil
+}
+
+func (idx *MemoryIndex) Delete(id int) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	return nil
+}
+
+// remove drops a document; the caller holds the write lock.
+func (idx *MemoryIndex) remove(id int) {
+	terms, ok := idx.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+			idx.vocabDirty = true
+		}
+	}
+	idx.totalLen -= idx.docLen[id]
+	delete(idx.docTerms, id)
+	delete(idx.docLen, id)
+}
+
+func (idx *MemoryIndex) Search(query string, limit int) ([]Result, error) {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	idx.mu.Lock()
+	if idx.vocabDirty {
+		idx.vocab = idx.vocab[:0]
+		for term := range idx.postings {
+			idx.vocab = append(idx.vocab, term)
+		}
+		sort.Strings(idx.vocab)
+		idx.vocabDirty = false
+	}
+	idx.mu.Unlock()
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Her sorgu kelimesi en az bir dokümanda eşleşmeli (AND)
+	var scores map[int]float64
+	for _, word := range words {
+		wordScores := make(map[int]float64)
+		for term, weight := range idx.expand(word) {
+			for id, score := range idx.scoreTerm(term) {
+				if s := weight * score; s > wordScores[id] {
+					wordScores[id] = s
+				}
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := wordScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{ID: id, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// expand returns the indexed words a query word matches and the weight of each
+// match. Typos are only considered when the word is not indexed as typed.
+func (idx *MemoryIndex) expand(word string) map[string]float64 {
+	matches := make(map[string]float64)
+	if _, ok := idx.postings[word]; ok {
+		matches[word] = exactMatch
+	}
+
+	if len([]rune(word)) >= 2 {
+		start := sort.SearchStrings(idx.vocab, word)
+		for i := start; i < len(idx.vocab) && strings.HasPrefix(idx.vocab[i], word) && len(matches) < maxExpansions; i++ {
+			if _, ok := matches[idx.vocab[i]]; !ok {
+				matches[idx.vocab[i]] = prefixMatch
+			}
+		}
+	}
+
+	if _, ok := matches[word]; !ok {
+		if edits := maxEdits(len([]rune(word))); edits > 0 {
+			for _, term := range idx.vocab {
+				if len(matches) >= maxExpansions {
+					break
+				}
+				if _, ok := matches[term]; ok {
+					continue
+				}
+				if d := editDistance(word, term, edits); d <= edits {
+					matches[term] = fuzzyMatch / float64(d)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// scoreTerm returns the BM25 score of term for every document containing it.
+func (idx *MemoryIndex) scoreTerm(term string) map[int]float64 {
+	docs := idx.postings[term]
+	if len(docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.docLen))
+	avgLen := idx.totalLen / n
+	idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+
+	scores := make(map[int]float64, len(docs))
+	for id, freq := range docs {
+		scores[id] = idf * freq * (k1 + 1) / (freq + k1*(1-b+b*idx.docLen[id]/avgLen))
+	}
+	return scores
+}
@@ -0,0 +1,23 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// asciiFold maps Turkish letters to the ASCII letters people type without a
+// Turkish keyboard, so "kazak" finds "Kazak" and "cay" finds "çay".
+var asciiFold = strings.NewReplacer("ı", "i", "ş", "s", "ğ", "g", "ü", "u", "ö", "o", "ç", "c", "â", "a", "î", "i", "û", "u")
+
+// Normalize lower-cases text with Turkish rules (İ→i, I→ı) and folds Turkish
+// letters to ASCII.
+func Normalize(text string) string {
+	return asciiFold.Replace(strings.ToLowerSpecial(unicode.TurkishCase, text))
+}
+
+// Tokenize splits normalized text into words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(Normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,28 @@
+// Package search provides product full-text search. Index is the extension
+// point; MemoryIndex is the built-in implementation that needs no external service.
+package search
+
+// Document is the searchable part of a product.
+type Document struct {
+	ID          int
+	Name        string
+	Description string
+	Category    string
+}
+
+// Result is a matching document and its relevance score; higher is better.
+type Result struct {
+	ID    int
+	Score float64
+}
+
+// Index is a full-text index of products.
+type Index interface {
+	// Index adds the document or replaces the one with the same ID.
+	Index(doc Document) error
+	// Delete removes the document with the given ID if it is indexed.
+	Delete(id int) error
+	// Search returns up to limit documents matching every word of query,
+	// best match first.
+	Search(query string, limit int) ([]Result, error)
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store keeps files in a bucket of an S3-compatible object storage. Requests
+// use path-style URLs (endpoint/bucket/key) and AWS Signature Version 4, which
+// AWS S3, MinIO and most other providers accept.
+type S3Store struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	client          *http.Client
+}
+
+// NewS3Store returns a store for bucket at endpoint, e.g. "https://s3.eu-central-1.amazonaws.com".
+func NewS3Store(endpoint, region, bucket, accessKeyID, secretAccessKey string) *S3Store {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Store{
+		Endpoint:        strings.TrimSuffix(endpoint, "/"),
+		Region:          region,
+		Bucket:          bucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3Store) Put(key string, r io.Reader, contentType string) error {
+	// İmza için içeriğin özeti gerekir; yüklenen dosyalar küçük olduğu için bellekte tutulur
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s.check(resp, key)
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(resp, key); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	req, err := s.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s.check(resp, key)
+}
+
+// check turns an unsuccessful response into an error.
+func (s *S3Store) check(resp *http.Response, key string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("storage: s3 %s: %s: %s", key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// request builds a signed request for the object key.
+func (s *S3Store) request(method, key string, body []byte) (*http.Request, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s.Endpoint + "/" + s.Bucket + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,61 @@
+// Package storage keeps uploaded files such as product images. Store is the
+// extension point; LocalStore writes to a directory and S3Store to any
+// S3-compatible object storage (AWS S3, MinIO, Cloudflare R2, ...).
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by Get and Delete when no file is stored under the key.
+var ErrNotFound = errors.New("storage: not found")
+
+// Store saves files under slash-separated keys such as "products/12/ab12.jpg".
+type Store interface {
+	// Put stores the content of r under key, replacing an existing file.
+	Put(key string, r io.Reader, contentType string) error
+	// Get opens the file stored under key.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key.
+	Delete(key string) error
+}
+
+// validKey rejects keys that could escape the storage root.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// Load builds the store selected by STORAGE_BACKEND: "local" (the default)
+// writes below STORAGE_DIR (default ./uploads); "s3" uses S3_ENDPOINT,
+// S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+func Load() (Store, error) {
+	switch backend := strings.ToLower(os.Getenv("STORAGE_BACKEND")); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		s := NewS3Store(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_REGION"), os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, errors.New("s3 storage needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}